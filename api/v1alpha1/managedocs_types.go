@@ -17,6 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,11 +34,308 @@ const (
 	// ReconcileStrategyStrict is used to indicate that the deployer should enforce
 	// storage clsuter based on a predefined spec
 	ReconcileStrategyStrict ReconcileStrategy = "strict"
+
+	// ReconcileStrategyMerge is used to indicate that the deployer should enforce
+	// storage cluster based on a predefined spec with user provided overrides applied on top
+	ReconcileStrategyMerge ReconcileStrategy = "merge"
+)
+
+// PatchType represent the kind of patch used to override a managed resource
+type PatchType string
+
+const (
+	// PatchTypeStrategicMerge is used for kubernetes strategic merge patches
+	PatchTypeStrategicMerge PatchType = "strategic"
+
+	// PatchTypeJSON is used for RFC 6902 JSON patches
+	PatchTypeJSON PatchType = "json"
+)
+
+// ComponentSpec defines the desired deployer behavior for a single managed component
+type ComponentSpec struct {
+	ReconcileStrategy ReconcileStrategy `json:"reconcileStrategy,omitempty"`
+}
+
+// StorageClusterOverrides holds a patch that is applied on top of the storage cluster
+// template when the storage cluster reconcile strategy is set to merge. The patch
+// targets the whole StorageCluster resource (e.g. {"spec": {...}}) and can be provided
+// inline or through a key of a ConfigMap in the deployer namespace. Changes to the
+// ConfigMap are picked up immediately only if it carries the
+// ocs.openshift.io/storagecluster-overrides label.
+type StorageClusterOverrides struct {
+	// PatchType defaults to strategic
+	PatchType       PatchType                    `json:"patchType,omitempty"`
+	Patch           string                       `json:"patch,omitempty"`
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// StorageClusterComponentSpec defines the desired deployer behavior for the storage cluster
+type StorageClusterComponentSpec struct {
+	ComponentSpec `json:",inline"`
+	Overrides     *StorageClusterOverrides `json:"overrides,omitempty"`
+
+	// MaxDeviceSetCountStep limits how many device sets are added to the storage cluster
+	// at once. Each step is only taken after the storage cluster is ready and the OSD
+	// volumes of the previous step are bound. Zero means no limit
+	// +kubebuilder:validation:Minimum=0
+	MaxDeviceSetCountStep int `json:"maxDeviceSetCountStep,omitempty"`
+}
+
+// ComponentSpecMap holds the per component settings. A component without an
+// explicit reconcile strategy is reconciled in strict mode, except for the
+// storage cluster which falls back to ManagedOCSSpec.ReconcileStrategy.
+// The merge strategy is only supported by the storage cluster, other components
+// treat it as strict
+type ComponentSpecMap struct {
+	StorageCluster     StorageClusterComponentSpec `json:"storageCluster,omitempty"`
+	Prometheus         ComponentSpec               `json:"prometheus,omitempty"`
+	Alertmanager       ComponentSpec               `json:"alertmanager,omitempty"`
+	AlertmanagerConfig ComponentSpec               `json:"alertmanagerConfig,omitempty"`
+	MonitoringLabels   ComponentSpec               `json:"monitoringLabels,omitempty"`
+}
+
+// StorageClassType represent the kind of ceph storage backing a storage class
+type StorageClassType string
+
+const (
+	// StorageClassTypeRBD is used for ceph block storage classes
+	StorageClassTypeRBD StorageClassType = "rbd"
+
+	// StorageClassTypeCephFS is used for ceph file system storage classes
+	StorageClassTypeCephFS StorageClassType = "cephfs"
+)
+
+// StorageClassSpec describes an additional storage class backed by the storage cluster.
+// The storage class starts from the parameters of the matching storage class created by
+// ocs-operator
+type StorageClassSpec struct {
+	Name string `json:"name"`
+
+	// Type is immutable, the volumes provisioned through the storage class are bound to the
+	// driver of its type. The other settings can be changed, the storage class is recreated
+	// when a setting that Kubernetes does not allow to update is changed
+	// +kubebuilder:validation:Enum=rbd;cephfs
+	Type StorageClassType `json:"type"`
+
+	ReclaimPolicy        *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	VolumeBindingMode    *storagev1.VolumeBindingMode          `json:"volumeBindingMode,omitempty"`
+	AllowVolumeExpansion *bool                                 `json:"allowVolumeExpansion,omitempty"`
+	MountOptions         []string                              `json:"mountOptions,omitempty"`
+
+	// FSType is the file system of rbd volumes, ext4 by default
+	FSType string `json:"fsType,omitempty"`
+
+	// Parameters are added to the default parameters of the storage class type,
+	// replacing default parameters with the same key
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// SnapshotClass requests a VolumeSnapshotClass with the same name as the storage class
+	SnapshotClass *VolumeSnapshotClassSpec `json:"snapshotClass,omitempty"`
+}
+
+// VolumeSnapshotClassSpec describes the VolumeSnapshotClass matching an additional storage class
+type VolumeSnapshotClassSpec struct {
+	// DeletionPolicy defaults to Delete
+	// +kubebuilder:validation:Enum=Delete;Retain
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// AlertRouteSpec routes the alerts matching all of its matchers to a receiver
+type AlertRouteSpec struct {
+	// Receiver is either one of the built-in pagerduty, pagerduty-low-urgency, null and
+	// DeadMansSnitch receivers or the name of a receiver secret. Receiver secrets are secrets
+	// in the deployer namespace labelled ocs.openshift.io/alertmanager-receiver with the
+	// receiver type (webhook, email, slack or opsgenie) as value
+	Receiver string `json:"receiver"`
+
+	// Match holds label values the alerts must have
+	Match map[string]string `json:"match,omitempty"`
+
+	// MatchRE holds regular expressions the alert label values must match
+	MatchRE map[string]string `json:"matchRE,omitempty"`
+
+	// Continue lets the alerts matching the route also be matched by the following routes,
+	// including the severity routes. Without it the matching alerts skip their severity route
+	Continue bool `json:"continue,omitempty"`
+}
+
+// SeverityRouteSpec configures the route of the alerts of a single severity. Empty fields
+// keep the deployer defaults
+type SeverityRouteSpec struct {
+	// Receiver is either one of the built-in pagerduty, pagerduty-low-urgency and null
+	// receivers or the name of a receiver secret. The null receiver drops the alerts
+	Receiver string `json:"receiver,omitempty"`
+
+	// The intervals use the alertmanager duration format, e.g. 30s, 5m or 4h
+	GroupWait      string `json:"groupWait,omitempty"`
+	GroupInterval  string `json:"groupInterval,omitempty"`
+	RepeatInterval string `json:"repeatInterval,omitempty"`
+}
+
+// SeverityRoutingSpec routes the alerts based on their severity label. By default, critical
+// alerts page, warning alerts open incidents on the low-urgency pagerduty service and info
+// alerts are dropped. The low-urgency service key is the PAGERDUTY_LOW_URGENCY_KEY entry of
+// the pagerduty secret, warning alerts are sent to the PAGERDUTY_KEY service when it is missing.
+// Alerts without a known severity follow the warning route
+type SeverityRoutingSpec struct {
+	Critical SeverityRouteSpec `json:"critical,omitempty"`
+	Warning  SeverityRouteSpec `json:"warning,omitempty"`
+	Info     SeverityRouteSpec `json:"info,omitempty"`
+}
+
+// DefaultInhibitRule names one of the inhibition rules added by the deployer
+// +kubebuilder:validation:Enum=critical-warning;cluster-error-osd;uninstall
+type DefaultInhibitRule string
+
+const (
+	// InhibitRuleCriticalWarning mutes warning alerts while a critical alert with the same
+	// name is firing in the same namespace
+	InhibitRuleCriticalWarning DefaultInhibitRule = "critical-warning"
+
+	// InhibitRuleClusterErrorOSD mutes the OSD alerts while the CephClusterErrorState
+	// alert is firing
+	InhibitRuleClusterErrorOSD DefaultInhibitRule = "cluster-error-osd"
+
+	// InhibitRuleUninstall mutes the storage alerts while the add-on is being uninstalled
+	InhibitRuleUninstall DefaultInhibitRule = "uninstall"
+)
+
+// InhibitRuleSpec mutes the alerts matching the target matchers while an alert matching
+// the source matchers is firing
+type InhibitRuleSpec struct {
+	SourceMatch   map[string]string `json:"sourceMatch,omitempty"`
+	SourceMatchRE map[string]string `json:"sourceMatchRE,omitempty"`
+	TargetMatch   map[string]string `json:"targetMatch,omitempty"`
+	TargetMatchRE map[string]string `json:"targetMatchRE,omitempty"`
+
+	// Equal lists the labels that must have the same value in the source and target alerts
+	Equal []string `json:"equal,omitempty"`
+}
+
+// CephAlertsSpec tunes the ceph alerts owned by the deployer. Empty fields keep the defaults
+type CephAlertsSpec struct {
+	// NearFullPercent is the used raw capacity percentage raising a warning, 75 by default
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	NearFullPercent int `json:"nearFullPercent,omitempty"`
+
+	// FullPercent is the used raw capacity percentage raising a critical alert, 85 by default
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	FullPercent int `json:"fullPercent,omitempty"`
+
+	// The durations use the prometheus duration format, e.g. 30s, 5m or 1h, and set how
+	// long a condition must hold before its alert fires
+	OSDDownFor       string `json:"osdDownFor,omitempty"`
+	MonQuorumLostFor string `json:"monQuorumLostFor,omitempty"`
+	PGStuckFor       string `json:"pgStuckFor,omitempty"`
+	SlowOpsFor       string `json:"slowOpsFor,omitempty"`
+
+	// RunbookBaseURL is the location of the runbooks, the runbook_url annotation of an
+	// alert is <RunbookBaseURL>/<alert name>.md
+	RunbookBaseURL string `json:"runbookBaseURL,omitempty"`
+}
+
+// AlertingSpec configures the routing of alerts by the deployer alertmanager
+type AlertingSpec struct {
+	Severities SeverityRoutingSpec `json:"severities,omitempty"`
+
+	// Routes are evaluated in order, after the dead man's snitch route and before the
+	// severity routes
+	Routes []AlertRouteSpec `json:"routes,omitempty"`
+
+	// DisabledInhibitRules lists the default inhibition rules to leave out
+	DisabledInhibitRules []DefaultInhibitRule `json:"disabledInhibitRules,omitempty"`
+
+	// InhibitRules are added after the default inhibition rules
+	InhibitRules []InhibitRuleSpec `json:"inhibitRules,omitempty"`
+
+	// Ceph tunes the ceph alerts owned by the deployer
+	Ceph CephAlertsSpec `json:"ceph,omitempty"`
+}
+
+// SilenceMatcher selects the alerts silenced during a maintenance window by one of their labels
+type SilenceMatcher struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+
+	// IsRegex matches the label value against Value as a regular expression
+	IsRegex bool `json:"isRegex,omitempty"`
+}
+
+// MaintenanceWindowSpec silences the matching alerts either once, between Start and End, or
+// every time Schedule fires, for Duration. The deployer creates an alertmanager silence for
+// the current or next occurrence of the window
+type MaintenanceWindowSpec struct {
+	Name string `json:"name"`
+
+	Start *metav1.Time `json:"start,omitempty"`
+	End   *metav1.Time `json:"end,omitempty"`
+
+	// Schedule is a cron expression evaluated in UTC, e.g. "0 2 * * 6" for every Saturday
+	// at 2am. A CRON_TZ= prefix selects another time zone
+	Schedule string           `json:"schedule,omitempty"`
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Matchers []SilenceMatcher `json:"matchers"`
+
+	Comment string `json:"comment,omitempty"`
+}
+
+// MonitoringResourceKind names one of the kinds of monitoring resources the deployer adopts
+// +kubebuilder:validation:Enum=PodMonitor;ServiceMonitor;PrometheusRule
+type MonitoringResourceKind string
+
+const (
+	MonitoringResourceKindPodMonitor     MonitoringResourceKind = "PodMonitor"
+	MonitoringResourceKindServiceMonitor MonitoringResourceKind = "ServiceMonitor"
+	MonitoringResourceKindPrometheusRule MonitoringResourceKind = "PrometheusRule"
 )
 
+// MonitoringResourceSelector selects monitoring resources. A resource is selected when it
+// matches all of the set fields
+type MonitoringResourceSelector struct {
+	// Kind restricts the selector to a single kind of monitoring resources
+	Kind MonitoringResourceKind `json:"kind,omitempty"`
+
+	// NamePattern is a shell pattern matched against the resource name, e.g. rook-ceph-*
+	NamePattern string `json:"namePattern,omitempty"`
+
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// MonitoringResourcesSpec filters the monitoring resources of the deployer namespace the
+// deployer prometheus picks up. Resources that are not adopted get the app=managed-ocs
+// label removed. The rules of the deployer are always adopted
+type MonitoringResourcesSpec struct {
+	// Allow lists the resources to adopt, all of the resources are allowed when it is empty
+	Allow []MonitoringResourceSelector `json:"allow,omitempty"`
+
+	// Deny lists the resources not to adopt, even when they are allowed
+	Deny []MonitoringResourceSelector `json:"deny,omitempty"`
+}
+
 // ManagedOCSSpec defines the desired state of ManagedOCS
 type ManagedOCSSpec struct {
 	ReconcileStrategy ReconcileStrategy `json:"reconcileStrategy,omitempty"`
+	Components        ComponentSpecMap  `json:"components,omitempty"`
+
+	// Paused stops the deployer from writing to any of the managed resources, including
+	// uninstalling them. Setting the ocs.openshift.io/paused annotation to "true" has
+	// the same effect
+	Paused bool `json:"paused,omitempty"`
+
+	// StorageClasses lists additional storage classes, on top of the ones created by
+	// ocs-operator. The deployer owns these storage classes and removes them on uninstall
+	StorageClasses []StorageClassSpec `json:"storageClasses,omitempty"`
+
+	Alerting AlertingSpec `json:"alerting,omitempty"`
+
+	// MaintenanceWindows silence alerts during planned maintenance
+	MaintenanceWindows []MaintenanceWindowSpec `json:"maintenanceWindows,omitempty"`
+
+	MonitoringResources MonitoringResourcesSpec `json:"monitoringResources,omitempty"`
 }
 
 type ComponentState string
@@ -55,19 +355,155 @@ type ComponentStatusMap struct {
 	StorageCluster ComponentStatus `json:"storageCluster"`
 	Prometheus     ComponentStatus `json:"prometheus"`
 	Alertmanager   ComponentStatus `json:"alertmanager"`
+
+	// NooBaa is only deployed when the multi-cloud gateway is enabled through the
+	// add-on parameters
+	NooBaa ComponentStatus `json:"noobaa,omitempty"`
+}
+
+// MaintenanceWindowStatus reports the alertmanager silence of a maintenance window
+type MaintenanceWindowStatus struct {
+	Name string `json:"name"`
+
+	// SilenceID is the id of the silence of the current or next occurrence of the window
+	SilenceID string       `json:"silenceID,omitempty"`
+	StartsAt  *metav1.Time `json:"startsAt,omitempty"`
+	EndsAt    *metav1.Time `json:"endsAt,omitempty"`
+}
+
+// MonitoringResourceReference identifies a monitoring resource of the deployer namespace
+type MonitoringResourceReference struct {
+	Kind MonitoringResourceKind `json:"kind"`
+	Name string                 `json:"name"`
+}
+
+// ScaleUpStatus reports the progress of a staged storage cluster scale-up
+type ScaleUpStatus struct {
+	// TargetDeviceSetCount is the device set count requested through the add-on parameters
+	TargetDeviceSetCount int `json:"targetDeviceSetCount,omitempty"`
+
+	// InProgress is set while the applied device set count is below the target
+	InProgress bool `json:"inProgress,omitempty"`
+
+	// Message describes the step currently taken or waited on
+	Message string `json:"message,omitempty"`
+}
+
+// CapacityStatus reports the storage capacity requested through the add-on
+// parameters and the capacity applied to the storage cluster
+type CapacityStatus struct {
+	// RequestedSize is the size add-on parameter as found in the add-on parameters secret
+	RequestedSize string `json:"requestedSize,omitempty"`
+
+	// DeviceSetCount and Replica are taken from the default storage device set
+	DeviceSetCount int `json:"deviceSetCount,omitempty"`
+	Replica        int `json:"replica,omitempty"`
+
+	// RawCapacity is the total size of all OSD volumes
+	RawCapacity *resource.Quantity `json:"rawCapacity,omitempty"`
+
+	// UsableCapacity is the raw capacity divided by the replica
+	UsableCapacity *resource.Quantity `json:"usableCapacity,omitempty"`
+
+	// DownscaleRejected is set when the requested size is smaller than the
+	// applied one, downscaling the storage cluster is not supported
+	DownscaleRejected bool `json:"downscaleRejected,omitempty"`
+
+	ScaleUp ScaleUpStatus `json:"scaleUp,omitempty"`
 }
 
+const (
+	// ConditionAvailable indicates that all of the managed components are ready
+	ConditionAvailable = "Available"
+
+	// ConditionProgressing indicates that the deployer is still working towards
+	// bringing the managed components to a ready state
+	ConditionProgressing = "Progressing"
+
+	// ConditionDegraded indicates that the last reconcile failed
+	ConditionDegraded = "Degraded"
+
+	// ConditionUninstallBlocked indicates that an uninstall was requested but
+	// cannot proceed
+	ConditionUninstallBlocked = "UninstallBlocked"
+
+	// ConditionConfigurationInvalid indicates that the configuration provided to
+	// the deployer (add-on parameters, alerting secrets, etc) is missing or invalid
+	ConditionConfigurationInvalid = "ConfigurationInvalid"
+
+	// ConditionInsufficientCapacity indicates that a storage cluster scale-up is held
+	// because the storage nodes do not have enough allocatable resources
+	ConditionInsufficientCapacity = "InsufficientCapacity"
+
+	// ConditionInsufficientZones indicates that the storage nodes span less than three
+	// zones, so the replicas cannot be spread across zones
+	ConditionInsufficientZones = "InsufficientZones"
+
+	// ConditionEncryptionChangeRejected indicates that the encryption add-on parameter
+	// asks to change the encryption of an existing storage cluster, which is not supported
+	ConditionEncryptionChangeRejected = "EncryptionChangeRejected"
+
+	// ConditionMaintenanceWindowsFailed indicates that the silences of the maintenance
+	// windows could not be reconciled, the other components are still reconciled
+	ConditionMaintenanceWindowsFailed = "MaintenanceWindowsFailed"
+)
+
 // ManagedOCSStatus defines the observed state of ManagedOCS
 type ManagedOCSStatus struct {
 	ReconcileStrategy ReconcileStrategy  `json:"reconcileStrategy,omitempty"`
 	Components        ComponentStatusMap `json:"components"`
+	Conditions        []metav1.Condition `json:"conditions,omitempty"`
+	Capacity          CapacityStatus     `json:"capacity,omitempty"`
+
+	// ResourceProfile is the resource profile applied to the storage cluster daemons
+	ResourceProfile string `json:"resourceProfile,omitempty"`
+
+	// Zones lists the zones of the storage nodes
+	Zones []string `json:"zones,omitempty"`
+
+	// StorageNodeCount is the number of nodes matching the storage cluster label selector,
+	// which are the nodes of the node pool when one is set through the add-on parameters
+	StorageNodeCount int `json:"storageNodeCount,omitempty"`
+
+	// MaintenanceWindows lists the silences created for the maintenance windows of the spec
+	MaintenanceWindows []MaintenanceWindowStatus `json:"maintenanceWindows,omitempty"`
+
+	// AdoptedMonitoringResources lists the monitoring resources labelled for the deployer
+	// prometheus, sorted by kind and name
+	AdoptedMonitoringResources []MonitoringResourceReference `json:"adoptedMonitoringResources,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the ManagedOCS resource
+	// that was reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastReconcileTime is the time of the most recent reconcile, successful or not
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastSuccessfulReconcileTime is the time of the most recent reconcile that
+	// completed without errors
+	LastSuccessfulReconcileTime *metav1.Time `json:"lastSuccessfulReconcileTime,omitempty"`
+
+	// LastError holds the (truncated) error of the last reconcile, prefixed with
+	// the name of the phase that failed. It is cleared on a successful reconcile
+	LastError string `json:"lastError,omitempty"`
+
+	// Paused reflects whether the deployer is currently paused
+	Paused bool `json:"paused,omitempty"`
+
+	// PausedSince is the time the deployer was paused
+	PausedSince *metav1.Time `json:"pausedSince,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=mocs
 
-// ManagedOCS is the Schema for the managedocs API
+// ManagedOCS is the Schema for the managedocs API.
+// The type of an additional storage class is the only immutable spec field. The settings
+// fixed once the storage cluster exists come from the add-on parameters secret instead: the
+// deployer ignores changes to the storage class of its volumes and to encryption, and does
+// not disable a managed multi-cloud gateway. Immutability is enforced by the validating
+// webhook, which is only served when the deployer runs with --enable-webhooks
 type ManagedOCS struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -21,9 +21,153 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertRouteSpec) DeepCopyInto(out *AlertRouteSpec) {
+	*out = *in
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MatchRE != nil {
+		in, out := &in.MatchRE, &out.MatchRE
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertRouteSpec.
+func (in *AlertRouteSpec) DeepCopy() *AlertRouteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertRouteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertingSpec) DeepCopyInto(out *AlertingSpec) {
+	*out = *in
+	out.Severities = in.Severities
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]AlertRouteSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DisabledInhibitRules != nil {
+		in, out := &in.DisabledInhibitRules, &out.DisabledInhibitRules
+		*out = make([]DefaultInhibitRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.InhibitRules != nil {
+		in, out := &in.InhibitRules, &out.InhibitRules
+		*out = make([]InhibitRuleSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Ceph = in.Ceph
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertingSpec.
+func (in *AlertingSpec) DeepCopy() *AlertingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityStatus) DeepCopyInto(out *CapacityStatus) {
+	*out = *in
+	if in.RawCapacity != nil {
+		in, out := &in.RawCapacity, &out.RawCapacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.UsableCapacity != nil {
+		in, out := &in.UsableCapacity, &out.UsableCapacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	out.ScaleUp = in.ScaleUp
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityStatus.
+func (in *CapacityStatus) DeepCopy() *CapacityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephAlertsSpec) DeepCopyInto(out *CephAlertsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephAlertsSpec.
+func (in *CephAlertsSpec) DeepCopy() *CephAlertsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CephAlertsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentSpec) DeepCopyInto(out *ComponentSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentSpec.
+func (in *ComponentSpec) DeepCopy() *ComponentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentSpecMap) DeepCopyInto(out *ComponentSpecMap) {
+	*out = *in
+	in.StorageCluster.DeepCopyInto(&out.StorageCluster)
+	out.Prometheus = in.Prometheus
+	out.Alertmanager = in.Alertmanager
+	out.AlertmanagerConfig = in.AlertmanagerConfig
+	out.MonitoringLabels = in.MonitoringLabels
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentSpecMap.
+func (in *ComponentSpecMap) DeepCopy() *ComponentSpecMap {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentSpecMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentStatus) DeepCopyInto(out *ComponentStatus) {
 	*out = *in
@@ -45,6 +189,7 @@ func (in *ComponentStatusMap) DeepCopyInto(out *ComponentStatusMap) {
 	out.StorageCluster = in.StorageCluster
 	out.Prometheus = in.Prometheus
 	out.Alertmanager = in.Alertmanager
+	out.NooBaa = in.NooBaa
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentStatusMap.
@@ -57,13 +202,117 @@ func (in *ComponentStatusMap) DeepCopy() *ComponentStatusMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InhibitRuleSpec) DeepCopyInto(out *InhibitRuleSpec) {
+	*out = *in
+	if in.SourceMatch != nil {
+		in, out := &in.SourceMatch, &out.SourceMatch
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SourceMatchRE != nil {
+		in, out := &in.SourceMatchRE, &out.SourceMatchRE
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TargetMatch != nil {
+		in, out := &in.TargetMatch, &out.TargetMatch
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TargetMatchRE != nil {
+		in, out := &in.TargetMatchRE, &out.TargetMatchRE
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Equal != nil {
+		in, out := &in.Equal, &out.Equal
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InhibitRuleSpec.
+func (in *InhibitRuleSpec) DeepCopy() *InhibitRuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InhibitRuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowSpec) DeepCopyInto(out *MaintenanceWindowSpec) {
+	*out = *in
+	if in.Start != nil {
+		in, out := &in.Start, &out.Start
+		*out = (*in).DeepCopy()
+	}
+	if in.End != nil {
+		in, out := &in.End, &out.End
+		*out = (*in).DeepCopy()
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Matchers != nil {
+		in, out := &in.Matchers, &out.Matchers
+		*out = make([]SilenceMatcher, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowSpec.
+func (in *MaintenanceWindowSpec) DeepCopy() *MaintenanceWindowSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowStatus) DeepCopyInto(out *MaintenanceWindowStatus) {
+	*out = *in
+	if in.StartsAt != nil {
+		in, out := &in.StartsAt, &out.StartsAt
+		*out = (*in).DeepCopy()
+	}
+	if in.EndsAt != nil {
+		in, out := &in.EndsAt, &out.EndsAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowStatus.
+func (in *MaintenanceWindowStatus) DeepCopy() *MaintenanceWindowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedOCS) DeepCopyInto(out *ManagedOCS) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedOCS.
@@ -119,6 +368,23 @@ func (in *ManagedOCSList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedOCSSpec) DeepCopyInto(out *ManagedOCSSpec) {
 	*out = *in
+	in.Components.DeepCopyInto(&out.Components)
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]StorageClassSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Alerting.DeepCopyInto(&out.Alerting)
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindowSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.MonitoringResources.DeepCopyInto(&out.MonitoringResources)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedOCSSpec.
@@ -135,6 +401,43 @@ func (in *ManagedOCSSpec) DeepCopy() *ManagedOCSSpec {
 func (in *ManagedOCSStatus) DeepCopyInto(out *ManagedOCSStatus) {
 	*out = *in
 	out.Components = in.Components
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Capacity.DeepCopyInto(&out.Capacity)
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindowStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdoptedMonitoringResources != nil {
+		in, out := &in.AdoptedMonitoringResources, &out.AdoptedMonitoringResources
+		*out = make([]MonitoringResourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulReconcileTime != nil {
+		in, out := &in.LastSuccessfulReconcileTime, &out.LastSuccessfulReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PausedSince != nil {
+		in, out := &in.PausedSince, &out.PausedSince
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedOCSStatus.
@@ -146,3 +449,233 @@ func (in *ManagedOCSStatus) DeepCopy() *ManagedOCSStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringResourceReference) DeepCopyInto(out *MonitoringResourceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringResourceReference.
+func (in *MonitoringResourceReference) DeepCopy() *MonitoringResourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringResourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringResourceSelector) DeepCopyInto(out *MonitoringResourceSelector) {
+	*out = *in
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringResourceSelector.
+func (in *MonitoringResourceSelector) DeepCopy() *MonitoringResourceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringResourceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringResourcesSpec) DeepCopyInto(out *MonitoringResourcesSpec) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]MonitoringResourceSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]MonitoringResourceSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringResourcesSpec.
+func (in *MonitoringResourcesSpec) DeepCopy() *MonitoringResourcesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringResourcesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleUpStatus) DeepCopyInto(out *ScaleUpStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleUpStatus.
+func (in *ScaleUpStatus) DeepCopy() *ScaleUpStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleUpStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SeverityRouteSpec) DeepCopyInto(out *SeverityRouteSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SeverityRouteSpec.
+func (in *SeverityRouteSpec) DeepCopy() *SeverityRouteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SeverityRouteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SeverityRoutingSpec) DeepCopyInto(out *SeverityRoutingSpec) {
+	*out = *in
+	out.Critical = in.Critical
+	out.Warning = in.Warning
+	out.Info = in.Info
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SeverityRoutingSpec.
+func (in *SeverityRoutingSpec) DeepCopy() *SeverityRoutingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SeverityRoutingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SilenceMatcher) DeepCopyInto(out *SilenceMatcher) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SilenceMatcher.
+func (in *SilenceMatcher) DeepCopy() *SilenceMatcher {
+	if in == nil {
+		return nil
+	}
+	out := new(SilenceMatcher)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassSpec) DeepCopyInto(out *StorageClassSpec) {
+	*out = *in
+	if in.ReclaimPolicy != nil {
+		in, out := &in.ReclaimPolicy, &out.ReclaimPolicy
+		*out = new(v1.PersistentVolumeReclaimPolicy)
+		**out = **in
+	}
+	if in.VolumeBindingMode != nil {
+		in, out := &in.VolumeBindingMode, &out.VolumeBindingMode
+		*out = new(storagev1.VolumeBindingMode)
+		**out = **in
+	}
+	if in.AllowVolumeExpansion != nil {
+		in, out := &in.AllowVolumeExpansion, &out.AllowVolumeExpansion
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SnapshotClass != nil {
+		in, out := &in.SnapshotClass, &out.SnapshotClass
+		*out = new(VolumeSnapshotClassSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassSpec.
+func (in *StorageClassSpec) DeepCopy() *StorageClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClusterComponentSpec) DeepCopyInto(out *StorageClusterComponentSpec) {
+	*out = *in
+	out.ComponentSpec = in.ComponentSpec
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = new(StorageClusterOverrides)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClusterComponentSpec.
+func (in *StorageClusterComponentSpec) DeepCopy() *StorageClusterComponentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClusterComponentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClusterOverrides) DeepCopyInto(out *StorageClusterOverrides) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClusterOverrides.
+func (in *StorageClusterOverrides) DeepCopy() *StorageClusterOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClusterOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotClassSpec) DeepCopyInto(out *VolumeSnapshotClassSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotClassSpec.
+func (in *VolumeSnapshotClassSpec) DeepCopy() *VolumeSnapshotClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,398 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+const (
+	// Secrets carrying this label define additional alertmanager receivers, the label value
+	// is the receiver type and the secret name is the receiver name
+	alertmanagerReceiverLabelKey = "ocs.openshift.io/alertmanager-receiver"
+
+	pagerdutyReceiverName           = "pagerduty"
+	pagerdutyLowUrgencyReceiverName = "pagerduty-low-urgency"
+	nullReceiverName                = "null"
+	dmsReceiverName                 = "DeadMansSnitch"
+
+	severityCritical = "critical"
+	severityWarning  = "warning"
+	severityInfo     = "info"
+
+	receiverTypeWebhook  = "webhook"
+	receiverTypeEmail    = "email"
+	receiverTypeSlack    = "slack"
+	receiverTypeOpsgenie = "opsgenie"
+
+	// The deployer fires this alert while the add-on is being uninstalled, it is the source
+	// of the uninstall inhibition rule
+	uninstallAlertName = "ManagedOCSUninstallInProgress"
+)
+
+// The alertmanager configuration is represented as go types to sanitize the input
+type alertmanagerConfig struct {
+	Route        *alertmanagerRoute        `yaml:"route"`
+	Receivers    []alertmanagerReceiver    `yaml:"receivers"`
+	InhibitRules []alertmanagerInhibitRule `yaml:"inhibit_rules,omitempty"`
+}
+
+type alertmanagerRoute struct {
+	GroupWait      string               `yaml:"group_wait,omitempty"`
+	GroupInterval  string               `yaml:"group_interval,omitempty"`
+	RepeatInterval string               `yaml:"repeat_interval,omitempty"`
+	Receiver       string               `yaml:"receiver,omitempty"`
+	Match          map[string]string    `yaml:"match,omitempty"`
+	MatchRE        map[string]string    `yaml:"match_re,omitempty"`
+	Continue       bool                 `yaml:"continue,omitempty"`
+	Routes         []*alertmanagerRoute `yaml:"routes,omitempty"`
+}
+
+type alertmanagerInhibitRule struct {
+	SourceMatch   map[string]string `yaml:"source_match,omitempty"`
+	SourceMatchRE map[string]string `yaml:"source_match_re,omitempty"`
+	TargetMatch   map[string]string `yaml:"target_match,omitempty"`
+	TargetMatchRE map[string]string `yaml:"target_match_re,omitempty"`
+	Equal         []string          `yaml:"equal,omitempty"`
+}
+
+type alertmanagerReceiver struct {
+	Name             string            `yaml:"name"`
+	PagerdutyConfigs []pagerdutyConfig `yaml:"pagerduty_configs,omitempty"`
+	WebhookConfigs   []webhookConfig   `yaml:"webhook_configs,omitempty"`
+	EmailConfigs     []emailConfig     `yaml:"email_configs,omitempty"`
+	SlackConfigs     []slackConfig     `yaml:"slack_configs,omitempty"`
+	OpsgenieConfigs  []opsgenieConfig  `yaml:"opsgenie_configs,omitempty"`
+}
+
+type pagerdutyConfig struct {
+	ServiceKey string `yaml:"service_key"`
+}
+
+type webhookConfig struct {
+	Url string `yaml:"url"`
+}
+
+type emailConfig struct {
+	To           string `yaml:"to"`
+	From         string `yaml:"from"`
+	Smarthost    string `yaml:"smarthost"`
+	AuthUsername string `yaml:"auth_username,omitempty"`
+	AuthPassword string `yaml:"auth_password,omitempty"`
+	RequireTLS   *bool  `yaml:"require_tls,omitempty"`
+}
+
+type slackConfig struct {
+	ApiUrl  string `yaml:"api_url"`
+	Channel string `yaml:"channel,omitempty"`
+}
+
+type opsgenieConfig struct {
+	ApiKey string `yaml:"api_key"`
+	ApiUrl string `yaml:"api_url,omitempty"`
+}
+
+// severityRouteDefaults holds the routes of the alerts by severity, the severity routing of
+// the ManagedOCS spec is applied on top of them
+var severityRouteDefaults = map[string]v1.SeverityRouteSpec{
+	severityCritical: {
+		Receiver:       pagerdutyReceiverName,
+		GroupWait:      "30s",
+		GroupInterval:  "5m",
+		RepeatInterval: "4h",
+	},
+	severityWarning: {
+		Receiver:       pagerdutyLowUrgencyReceiverName,
+		GroupWait:      "5m",
+		GroupInterval:  "10m",
+		RepeatInterval: "12h",
+	},
+	severityInfo: {
+		Receiver:       nullReceiverName,
+		GroupWait:      "5m",
+		GroupInterval:  "30m",
+		RepeatInterval: "24h",
+	},
+}
+
+// newSeverityRoute builds the route of the alerts of a severity from the defaults and the
+// non-empty fields of the spec
+func newSeverityRoute(severity string, spec *v1.SeverityRouteSpec) *alertmanagerRoute {
+	merged := severityRouteDefaults[severity]
+	if spec.Receiver != "" {
+		merged.Receiver = spec.Receiver
+	}
+	if spec.GroupWait != "" {
+		merged.GroupWait = spec.GroupWait
+	}
+	if spec.GroupInterval != "" {
+		merged.GroupInterval = spec.GroupInterval
+	}
+	if spec.RepeatInterval != "" {
+		merged.RepeatInterval = spec.RepeatInterval
+	}
+	return &alertmanagerRoute{
+		GroupWait:      merged.GroupWait,
+		GroupInterval:  merged.GroupInterval,
+		RepeatInterval: merged.RepeatInterval,
+		Receiver:       merged.Receiver,
+		Match:          map[string]string{"severity": severity},
+	}
+}
+
+// newDefaultInhibitRules builds the inhibition rules of the deployer, leaving out the
+// rules disabled in the spec
+func (r *ManagedOCSReconciler) newDefaultInhibitRules(disabled []v1.DefaultInhibitRule) []alertmanagerInhibitRule {
+	defaults := []struct {
+		name v1.DefaultInhibitRule
+		rule alertmanagerInhibitRule
+	}{
+		{
+			v1.InhibitRuleCriticalWarning,
+			alertmanagerInhibitRule{
+				SourceMatch: map[string]string{"severity": severityCritical},
+				TargetMatch: map[string]string{"severity": severityWarning},
+				Equal:       []string{"namespace", "alertname"},
+			},
+		},
+		{
+			v1.InhibitRuleClusterErrorOSD,
+			alertmanagerInhibitRule{
+				SourceMatch:   map[string]string{"alertname": "CephClusterErrorState"},
+				TargetMatchRE: map[string]string{"alertname": "(ManagedOCS)?CephOSD.+"},
+				Equal:         []string{"namespace"},
+			},
+		},
+		{
+			// The dead man's snitch alert does not carry a namespace and keeps firing
+			v1.InhibitRuleUninstall,
+			alertmanagerInhibitRule{
+				SourceMatch: map[string]string{"alertname": uninstallAlertName},
+				TargetMatch: map[string]string{"namespace": r.namespace},
+			},
+		},
+	}
+
+	rules := []alertmanagerInhibitRule{}
+	for _, item := range defaults {
+		if !containsInhibitRule(disabled, item.name) {
+			rules = append(rules, item.rule)
+		}
+	}
+	return rules
+}
+
+func containsInhibitRule(rules []v1.DefaultInhibitRule, name v1.DefaultInhibitRule) bool {
+	for _, rule := range rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Prometheus-operator v0.37.0 takes its alert manager configuration from a secret containing a yaml file.
+// This function produces the content of that yaml file.
+func (r *ManagedOCSReconciler) generateAlertmanagerConfig(pagerdutyServiceKey string, pagerdutyLowUrgencyServiceKey string, dmsURL string, receivers []alertmanagerReceiver) (*alertmanagerConfig, error) {
+	alerting := &r.managedOCS.Spec.Alerting
+	criticalRoute := newSeverityRoute(severityCritical, &alerting.Severities.Critical)
+	warningRoute := newSeverityRoute(severityWarning, &alerting.Severities.Warning)
+	infoRoute := newSeverityRoute(severityInfo, &alerting.Severities.Info)
+
+	// Events API v1 events carry no severity, the urgency of the incidents is set by the
+	// pagerduty service. Without a low-urgency service the alerts go to the pagerduty service
+	// rather than being dropped
+	if pagerdutyLowUrgencyServiceKey == "" {
+		pagerdutyLowUrgencyServiceKey = pagerdutyServiceKey
+	}
+	lowUrgencyReceiver := alertmanagerReceiver{
+		Name:             pagerdutyLowUrgencyReceiverName,
+		PagerdutyConfigs: []pagerdutyConfig{{ServiceKey: pagerdutyLowUrgencyServiceKey}},
+	}
+
+	config := &alertmanagerConfig{
+		Route: &alertmanagerRoute{
+			// Alerts without a known severity follow the warning route
+			GroupWait:      warningRoute.GroupWait,
+			GroupInterval:  warningRoute.GroupInterval,
+			RepeatInterval: warningRoute.RepeatInterval,
+			Receiver:       warningRoute.Receiver,
+			Routes: []*alertmanagerRoute{
+				{
+					GroupWait:      "5m",
+					GroupInterval:  "5m",
+					RepeatInterval: "5m",
+					Receiver:       dmsReceiverName,
+					Match:          map[string]string{"alertname": "DeadMansSnitch"},
+				},
+			},
+		},
+		Receivers: []alertmanagerReceiver{
+			{
+				Name:             pagerdutyReceiverName,
+				PagerdutyConfigs: []pagerdutyConfig{{ServiceKey: pagerdutyServiceKey}},
+			},
+			lowUrgencyReceiver,
+			{
+				Name: nullReceiverName,
+			},
+			{
+				Name:           dmsReceiverName,
+				WebhookConfigs: []webhookConfig{{Url: dmsURL}},
+			},
+		},
+	}
+	config.Receivers = append(config.Receivers, receivers...)
+
+	// The routes of the spec are evaluated before the severity routes, an alert only reaches
+	// its severity route when it matches no spec route or the matching spec routes continue
+	for i := range alerting.Routes {
+		specRoute := &alerting.Routes[i]
+		config.Route.Routes = append(config.Route.Routes, &alertmanagerRoute{
+			Receiver: specRoute.Receiver,
+			Match:    specRoute.Match,
+			MatchRE:  specRoute.MatchRE,
+			Continue: specRoute.Continue,
+		})
+	}
+	config.Route.Routes = append(config.Route.Routes, criticalRoute, warningRoute, infoRoute)
+
+	config.InhibitRules = r.newDefaultInhibitRules(alerting.DisabledInhibitRules)
+	for i := range alerting.InhibitRules {
+		specRule := &alerting.InhibitRules[i]
+		config.InhibitRules = append(config.InhibitRules, alertmanagerInhibitRule{
+			SourceMatch:   specRule.SourceMatch,
+			SourceMatchRE: specRule.SourceMatchRE,
+			TargetMatch:   specRule.TargetMatch,
+			TargetMatchRE: specRule.TargetMatchRE,
+			Equal:         specRule.Equal,
+		})
+	}
+
+	receiverNames := map[string]bool{}
+	for i := range config.Receivers {
+		receiverNames[config.Receivers[i].Name] = true
+	}
+	for _, route := range config.Route.Routes {
+		if !receiverNames[route.Receiver] {
+			return nil, newConfigurationError(reasonAlertmanagerReceiverError,
+				"Alert route refers to an unknown receiver %q", route.Receiver)
+		}
+	}
+
+	return config, nil
+}
+
+// getAlertmanagerReceivers builds the receivers defined by the receiver secrets of the
+// deployer namespace, sorted by name
+func (r *ManagedOCSReconciler) getAlertmanagerReceivers() ([]alertmanagerReceiver, error) {
+	secretList := &corev1.SecretList{}
+	if err := r.Client.List(r.ctx, secretList, client.InNamespace(r.namespace), client.HasLabels{alertmanagerReceiverLabelKey}); err != nil {
+		return nil, fmt.Errorf("unable to list alertmanager receiver secrets: %v", err)
+	}
+	sort.Slice(secretList.Items, func(i, j int) bool {
+		return secretList.Items[i].Name < secretList.Items[j].Name
+	})
+
+	receivers := []alertmanagerReceiver{}
+	for i := range secretList.Items {
+		receiver, err := newAlertmanagerReceiver(&secretList.Items[i])
+		if err != nil {
+			return nil, newConfigurationError(reasonAlertmanagerReceiverError,
+				"Invalid alertmanager receiver secret %s: %v", secretList.Items[i].Name, err)
+		}
+		receivers = append(receivers, receiver)
+	}
+	return receivers, nil
+}
+
+// newAlertmanagerReceiver builds a receiver from a receiver secret. The secret keys follow
+// the names of the alertmanager receiver settings
+func newAlertmanagerReceiver(secret *corev1.Secret) (alertmanagerReceiver, error) {
+	receiver := alertmanagerReceiver{Name: secret.Name}
+	switch receiver.Name {
+	case pagerdutyReceiverName, pagerdutyLowUrgencyReceiverName, nullReceiverName, dmsReceiverName:
+		return receiver, fmt.Errorf("the receiver name is reserved")
+	}
+
+	required := func(key string) (string, error) {
+		value := string(secret.Data[key])
+		if value == "" {
+			return "", fmt.Errorf("secret does not contain a %s entry", key)
+		}
+		return value, nil
+	}
+
+	receiverType := secret.Labels[alertmanagerReceiverLabelKey]
+	switch receiverType {
+	case receiverTypeWebhook:
+		url, err := required("url")
+		if err != nil {
+			return receiver, err
+		}
+		receiver.WebhookConfigs = []webhookConfig{{Url: url}}
+
+	case receiverTypeEmail:
+		config := emailConfig{
+			AuthUsername: string(secret.Data["auth_username"]),
+			AuthPassword: string(secret.Data["auth_password"]),
+		}
+		var err error
+		if config.To, err = required("to"); err != nil {
+			return receiver, err
+		}
+		if config.From, err = required("from"); err != nil {
+			return receiver, err
+		}
+		if config.Smarthost, err = required("smarthost"); err != nil {
+			return receiver, err
+		}
+		if value, ok := secret.Data["require_tls"]; ok {
+			requireTLS, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return receiver, fmt.Errorf("invalid require_tls value: %v", string(value))
+			}
+			config.RequireTLS = &requireTLS
+		}
+		receiver.EmailConfigs = []emailConfig{config}
+
+	case receiverTypeSlack:
+		apiURL, err := required("api_url")
+		if err != nil {
+			return receiver, err
+		}
+		receiver.SlackConfigs = []slackConfig{{ApiUrl: apiURL, Channel: string(secret.Data["channel"])}}
+
+	case receiverTypeOpsgenie:
+		apiKey, err := required("api_key")
+		if err != nil {
+			return receiver, err
+		}
+		receiver.OpsgenieConfigs = []opsgenieConfig{{ApiKey: apiKey, ApiUrl: string(secret.Data["api_url"])}}
+
+	default:
+		return receiver, fmt.Errorf("unsupported receiver type %q", receiverType)
+	}
+	return receiver, nil
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+// generateAlertmanagerConfigYAML renders the alertmanager config of the spec the same way
+// reconcileAlertmanagerConfigSecret does, and decodes it back into generic maps
+func generateAlertmanagerConfigYAML(t *testing.T, spec v1.ManagedOCSSpec, receivers []alertmanagerReceiver) map[string]interface{} {
+	r := &ManagedOCSReconciler{managedOCS: &v1.ManagedOCS{Spec: spec}}
+	config, err := r.generateAlertmanagerConfig("test-key", "test-low-urgency-key", "http://snitch", receivers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("unable to encode the config: %v", err)
+	}
+	decoded := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unable to decode the config: %v", err)
+	}
+	return decoded
+}
+
+func findSeverityRoute(t *testing.T, config map[string]interface{}, severity string) map[string]interface{} {
+	route := config["route"].(map[string]interface{})
+	for _, item := range route["routes"].([]interface{}) {
+		child := item.(map[string]interface{})
+		if match, ok := child["match"].(map[string]interface{}); ok && match["severity"] == severity {
+			return child
+		}
+	}
+	t.Fatalf("no route for severity %s", severity)
+	return nil
+}
+
+func expectRoute(t *testing.T, route map[string]interface{}, receiver, groupWait, groupInterval, repeatInterval string) {
+	expected := map[string]string{
+		"receiver":        receiver,
+		"group_wait":      groupWait,
+		"group_interval":  groupInterval,
+		"repeat_interval": repeatInterval,
+	}
+	for key, value := range expected {
+		if route[key] != value {
+			t.Errorf("expected %s to be %q, found %v", key, value, route[key])
+		}
+	}
+}
+
+func TestAlertmanagerConfigSeverityRoutingDefaults(t *testing.T) {
+	config := generateAlertmanagerConfigYAML(t, v1.ManagedOCSSpec{}, nil)
+
+	expectRoute(t, findSeverityRoute(t, config, severityCritical), pagerdutyReceiverName, "30s", "5m", "4h")
+	expectRoute(t, findSeverityRoute(t, config, severityWarning), pagerdutyLowUrgencyReceiverName, "5m", "10m", "12h")
+	expectRoute(t, findSeverityRoute(t, config, severityInfo), nullReceiverName, "5m", "30m", "24h")
+
+	// Alerts without a severity must not page
+	route := config["route"].(map[string]interface{})
+	if route["receiver"] != pagerdutyLowUrgencyReceiverName {
+		t.Errorf("expected the default receiver to be %s, found %v", pagerdutyLowUrgencyReceiverName, route["receiver"])
+	}
+
+	// The dead man's snitch route must come before the severity routes
+	first := route["routes"].([]interface{})[0].(map[string]interface{})
+	if first["receiver"] != dmsReceiverName {
+		t.Errorf("expected the first route to be the %s route, found %v", dmsReceiverName, first["receiver"])
+	}
+
+	if null := findReceiver(t, config, nullReceiverName); len(null) != 1 {
+		t.Errorf("expected the null receiver to have no configs, found %v", null)
+	}
+}
+
+func TestAlertmanagerConfigSeverityRoutingOverrides(t *testing.T) {
+	spec := v1.ManagedOCSSpec{}
+	spec.Alerting.Severities.Warning = v1.SeverityRouteSpec{
+		Receiver:  "tickets",
+		GroupWait: "1m",
+	}
+	receivers := []alertmanagerReceiver{{
+		Name:           "tickets",
+		WebhookConfigs: []webhookConfig{{Url: "http://tickets"}},
+	}}
+	config := generateAlertmanagerConfigYAML(t, spec, receivers)
+
+	expectRoute(t, findSeverityRoute(t, config, severityCritical), pagerdutyReceiverName, "30s", "5m", "4h")
+	expectRoute(t, findSeverityRoute(t, config, severityWarning), "tickets", "1m", "10m", "12h")
+	expectRoute(t, findSeverityRoute(t, config, severityInfo), nullReceiverName, "5m", "30m", "24h")
+}
+
+func TestAlertmanagerConfigSpecRoutesPrecedeSeverityRoutes(t *testing.T) {
+	spec := v1.ManagedOCSSpec{}
+	spec.Alerting.Routes = []v1.AlertRouteSpec{{
+		Receiver: nullReceiverName,
+		Match:    map[string]string{"alertname": "CephClusterWarningState"},
+	}}
+	config := generateAlertmanagerConfigYAML(t, spec, nil)
+
+	for _, severity := range []string{severityCritical, severityWarning, severityInfo} {
+		if _, ok := findSeverityRoute(t, config, severity)["continue"]; ok {
+			t.Errorf("expected the %s route not to continue", severity)
+		}
+	}
+	routes := config["route"].(map[string]interface{})["routes"].([]interface{})
+	if len(routes) != 5 {
+		t.Fatalf("expected 5 routes, found %d", len(routes))
+	}
+	specRoute := routes[1].(map[string]interface{})
+	if specRoute["match"].(map[string]interface{})["alertname"] != "CephClusterWarningState" {
+		t.Errorf("expected the spec route to follow the dead man's snitch route, found %v", specRoute)
+	}
+}
+
+func findReceiver(t *testing.T, config map[string]interface{}, name string) map[string]interface{} {
+	for _, item := range config["receivers"].([]interface{}) {
+		receiver := item.(map[string]interface{})
+		if receiver["name"] == name {
+			return receiver
+		}
+	}
+	t.Fatalf("no receiver named %s", name)
+	return nil
+}
+
+func TestAlertmanagerConfigLowUrgencyReceiverUsesSeparateServiceKey(t *testing.T) {
+	config := generateAlertmanagerConfigYAML(t, v1.ManagedOCSSpec{}, nil)
+
+	expected := map[string]string{
+		pagerdutyReceiverName:           "test-key",
+		pagerdutyLowUrgencyReceiverName: "test-low-urgency-key",
+	}
+	for name, serviceKey := range expected {
+		configs := findReceiver(t, config, name)["pagerduty_configs"].([]interface{})
+		if len(configs) != 1 {
+			t.Fatalf("expected a single pagerduty config for %s, found %v", name, configs)
+		}
+		if key := configs[0].(map[string]interface{})["service_key"]; key != serviceKey {
+			t.Errorf("expected %s to use the %q service key, found %v", name, serviceKey, key)
+		}
+	}
+}
+
+func TestAlertmanagerConfigLowUrgencyReceiverWithoutServiceKey(t *testing.T) {
+	r := &ManagedOCSReconciler{managedOCS: &v1.ManagedOCS{}}
+	config, err := r.generateAlertmanagerConfig("test-key", "", "http://snitch", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, receiver := range config.Receivers {
+		if receiver.Name != pagerdutyLowUrgencyReceiverName {
+			continue
+		}
+		if len(receiver.PagerdutyConfigs) != 1 || receiver.PagerdutyConfigs[0].ServiceKey != "test-key" {
+			t.Errorf("expected the low-urgency receiver to fall back to the pagerduty service key, found %v", receiver.PagerdutyConfigs)
+		}
+		return
+	}
+	t.Fatalf("no receiver named %s", pagerdutyLowUrgencyReceiverName)
+}
+
+func TestAlertmanagerConfigRejectsUnknownReceiver(t *testing.T) {
+	spec := v1.ManagedOCSSpec{}
+	spec.Alerting.Severities.Warning.Receiver = "does-not-exist"
+	r := &ManagedOCSReconciler{managedOCS: &v1.ManagedOCS{Spec: spec}}
+	if _, err := r.generateAlertmanagerConfig("test-key", "test-low-urgency-key", "http://snitch", nil); err == nil {
+		t.Fatalf("expected an unknown receiver error")
+	}
+}
+
+func TestAlertmanagerConfigDefaultInhibitRules(t *testing.T) {
+	r := &ManagedOCSReconciler{managedOCS: &v1.ManagedOCS{}, namespace: "openshift-storage"}
+	config, err := r.generateAlertmanagerConfig("test-key", "test-low-urgency-key", "http://snitch", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(config.InhibitRules) != 3 {
+		t.Fatalf("expected 3 inhibit rules, found %d", len(config.InhibitRules))
+	}
+
+	critical := config.InhibitRules[0]
+	if critical.SourceMatch["severity"] != severityCritical || critical.TargetMatch["severity"] != severityWarning {
+		t.Errorf("expected critical alerts to inhibit warning alerts, found %v", critical)
+	}
+	osd := config.InhibitRules[1]
+	if osd.SourceMatch["alertname"] != "CephClusterErrorState" || osd.TargetMatchRE["alertname"] != "(ManagedOCS)?CephOSD.+" {
+		t.Errorf("expected the cluster error state to inhibit the osd alerts, found %v", osd)
+	}
+	uninstall := config.InhibitRules[2]
+	if uninstall.SourceMatch["alertname"] != uninstallAlertName || uninstall.TargetMatch["namespace"] != "openshift-storage" {
+		t.Errorf("expected the uninstall alert to inhibit the storage alerts, found %v", uninstall)
+	}
+}
+
+func TestAlertmanagerConfigConfigurableInhibitRules(t *testing.T) {
+	spec := v1.ManagedOCSSpec{}
+	spec.Alerting.DisabledInhibitRules = []v1.DefaultInhibitRule{v1.InhibitRuleCriticalWarning, v1.InhibitRuleUninstall}
+	spec.Alerting.InhibitRules = []v1.InhibitRuleSpec{{
+		SourceMatch: map[string]string{"alertname": "CephMonQuorumAtRisk"},
+		TargetMatch: map[string]string{"alertname": "CephMonDown"},
+		Equal:       []string{"namespace"},
+	}}
+	config := generateAlertmanagerConfigYAML(t, spec, nil)
+
+	rules := config["inhibit_rules"].([]interface{})
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 inhibit rules, found %v", rules)
+	}
+	first := rules[0].(map[string]interface{})
+	if first["source_match"].(map[string]interface{})["alertname"] != "CephClusterErrorState" {
+		t.Errorf("expected the osd inhibit rule to be kept, found %v", first)
+	}
+	last := rules[1].(map[string]interface{})
+	if last["target_match"].(map[string]interface{})["alertname"] != "CephMonDown" {
+		t.Errorf("expected the spec inhibit rule to follow the default rules, found %v", last)
+	}
+}
+
+func TestNewAlertmanagerReceiver(t *testing.T) {
+	requireTLS := false
+	tests := []struct {
+		name         string
+		secretName   string
+		receiverType string
+		data         map[string]string
+		expected     alertmanagerReceiver
+		expectError  bool
+	}{
+		{
+			name:         "webhook",
+			secretName:   "tickets",
+			receiverType: receiverTypeWebhook,
+			data:         map[string]string{"url": "http://tickets"},
+			expected:     alertmanagerReceiver{Name: "tickets", WebhookConfigs: []webhookConfig{{Url: "http://tickets"}}},
+		},
+		{
+			name:         "webhook without url",
+			secretName:   "tickets",
+			receiverType: receiverTypeWebhook,
+			expectError:  true,
+		},
+		{
+			name:         "email",
+			secretName:   "mail",
+			receiverType: receiverTypeEmail,
+			data: map[string]string{
+				"to":            "sre@example.com",
+				"from":          "alerts@example.com",
+				"smarthost":     "smtp.example.com:587",
+				"auth_username": "alerts",
+				"auth_password": "secret",
+				"require_tls":   "false",
+			},
+			expected: alertmanagerReceiver{Name: "mail", EmailConfigs: []emailConfig{{
+				To:           "sre@example.com",
+				From:         "alerts@example.com",
+				Smarthost:    "smtp.example.com:587",
+				AuthUsername: "alerts",
+				AuthPassword: "secret",
+				RequireTLS:   &requireTLS,
+			}}},
+		},
+		{
+			name:         "email without require_tls",
+			secretName:   "mail",
+			receiverType: receiverTypeEmail,
+			data:         map[string]string{"to": "sre@example.com", "from": "alerts@example.com", "smarthost": "smtp.example.com:587"},
+			expected: alertmanagerReceiver{Name: "mail", EmailConfigs: []emailConfig{{
+				To:        "sre@example.com",
+				From:      "alerts@example.com",
+				Smarthost: "smtp.example.com:587",
+			}}},
+		},
+		{
+			name:         "email with an invalid require_tls",
+			secretName:   "mail",
+			receiverType: receiverTypeEmail,
+			data:         map[string]string{"to": "sre@example.com", "from": "alerts@example.com", "smarthost": "smtp.example.com:587", "require_tls": "maybe"},
+			expectError:  true,
+		},
+		{
+			name:         "email without smarthost",
+			secretName:   "mail",
+			receiverType: receiverTypeEmail,
+			data:         map[string]string{"to": "sre@example.com", "from": "alerts@example.com"},
+			expectError:  true,
+		},
+		{
+			name:         "slack",
+			secretName:   "chat",
+			receiverType: receiverTypeSlack,
+			data:         map[string]string{"api_url": "https://hooks.slack.com/services/x", "channel": "#storage"},
+			expected:     alertmanagerReceiver{Name: "chat", SlackConfigs: []slackConfig{{ApiUrl: "https://hooks.slack.com/services/x", Channel: "#storage"}}},
+		},
+		{
+			name:         "slack without api_url",
+			secretName:   "chat",
+			receiverType: receiverTypeSlack,
+			data:         map[string]string{"channel": "#storage"},
+			expectError:  true,
+		},
+		{
+			name:         "opsgenie",
+			secretName:   "oncall",
+			receiverType: receiverTypeOpsgenie,
+			data:         map[string]string{"api_key": "key"},
+			expected:     alertmanagerReceiver{Name: "oncall", OpsgenieConfigs: []opsgenieConfig{{ApiKey: "key"}}},
+		},
+		{
+			name:         "opsgenie without api_key",
+			secretName:   "oncall",
+			receiverType: receiverTypeOpsgenie,
+			data:         map[string]string{"api_url": "https://api.opsgenie.com"},
+			expectError:  true,
+		},
+		{
+			name:         "reserved name",
+			secretName:   pagerdutyReceiverName,
+			receiverType: receiverTypeWebhook,
+			data:         map[string]string{"url": "http://tickets"},
+			expectError:  true,
+		},
+		{
+			name:         "reserved dead man's snitch name",
+			secretName:   dmsReceiverName,
+			receiverType: receiverTypeWebhook,
+			data:         map[string]string{"url": "http://tickets"},
+			expectError:  true,
+		},
+		{
+			name:         "unknown type",
+			secretName:   "tickets",
+			receiverType: "pager",
+			data:         map[string]string{"url": "http://tickets"},
+			expectError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   test.secretName,
+					Labels: map[string]string{alertmanagerReceiverLabelKey: test.receiverType},
+				},
+				Data: map[string][]byte{},
+			}
+			for key, value := range test.data {
+				secret.Data[key] = []byte(value)
+			}
+
+			receiver, err := newAlertmanagerReceiver(secret)
+			if test.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, found %+v", receiver)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(receiver, test.expected) {
+				t.Errorf("expected %+v, found %+v", test.expected, receiver)
+			}
+		})
+	}
+}
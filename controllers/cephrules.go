@@ -0,0 +1,154 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	"github.com/openshift/ocs-osd-deployer/utils"
+)
+
+// The ceph alerts owned by the deployer are prefixed to keep them apart from the alerts
+// of the rook and ocs-operator rules
+const (
+	cephClusterNearFullAlert  = "ManagedOCSCephClusterNearFull"
+	cephClusterFullAlert      = "ManagedOCSCephClusterFull"
+	cephOSDDownAlert          = "ManagedOCSCephOSDDown"
+	cephMonQuorumLostAlert    = "ManagedOCSCephMonQuorumLost"
+	cephPGStuckAlert          = "ManagedOCSCephPGStuck"
+	cephSlowOpsAlert          = "ManagedOCSCephSlowOps"
+	cephCapacityFor           = "5m"
+	defaultCephRunbookBaseURL = "https://github.com/openshift/ocs-osd-deployer/blob/main/docs/runbooks"
+)
+
+// cephAlertDefaults holds the thresholds of the ceph alerts, the ceph alerts spec of the
+// ManagedOCS spec is applied on top of them
+var cephAlertDefaults = v1.CephAlertsSpec{
+	NearFullPercent:  75,
+	FullPercent:      85,
+	OSDDownFor:       "5m",
+	MonQuorumLostFor: "1m",
+	PGStuckFor:       "15m",
+	SlowOpsFor:       "30s",
+	RunbookBaseURL:   defaultCephRunbookBaseURL,
+}
+
+// mergeCephAlertsSpec returns the defaults overridden by the non-empty fields of the spec
+func mergeCephAlertsSpec(spec *v1.CephAlertsSpec) v1.CephAlertsSpec {
+	merged := cephAlertDefaults
+	if spec.NearFullPercent != 0 {
+		merged.NearFullPercent = spec.NearFullPercent
+	}
+	if spec.FullPercent != 0 {
+		merged.FullPercent = spec.FullPercent
+	}
+	if spec.OSDDownFor != "" {
+		merged.OSDDownFor = spec.OSDDownFor
+	}
+	if spec.MonQuorumLostFor != "" {
+		merged.MonQuorumLostFor = spec.MonQuorumLostFor
+	}
+	if spec.PGStuckFor != "" {
+		merged.PGStuckFor = spec.PGStuckFor
+	}
+	if spec.SlowOpsFor != "" {
+		merged.SlowOpsFor = spec.SlowOpsFor
+	}
+	if spec.RunbookBaseURL != "" {
+		merged.RunbookBaseURL = spec.RunbookBaseURL
+	}
+	return merged
+}
+
+// newCephAlertRules builds the ceph alerts from the ceph alerts spec. The alerts carry the
+// namespace label of the ceph metrics
+func newCephAlertRules(spec *v1.CephAlertsSpec) []promv1.Rule {
+	merged := mergeCephAlertsSpec(spec)
+	newRule := func(alert string, severity string, expr string, duration string, summary string, description string) promv1.Rule {
+		return promv1.Rule{
+			Alert:  alert,
+			Expr:   intstr.FromString(expr),
+			For:    duration,
+			Labels: map[string]string{"severity": severity},
+			Annotations: map[string]string{
+				"summary":     summary,
+				"description": description,
+				"runbook_url": fmt.Sprintf("%s/%s.md", strings.TrimSuffix(merged.RunbookBaseURL, "/"), alert),
+			},
+		}
+	}
+	usedPercent := "sum by (namespace) (ceph_cluster_total_used_raw_bytes) / sum by (namespace) (ceph_cluster_total_bytes) * 100"
+
+	return []promv1.Rule{
+		newRule(cephClusterNearFullAlert, severityWarning,
+			fmt.Sprintf("%s > %d", usedPercent, merged.NearFullPercent), cephCapacityFor,
+			"Storage cluster is nearing full capacity",
+			fmt.Sprintf("Storage cluster utilization has crossed %d%%. Free up some space or expand the storage cluster.", merged.NearFullPercent)),
+		newRule(cephClusterFullAlert, severityCritical,
+			fmt.Sprintf("%s > %d", usedPercent, merged.FullPercent), cephCapacityFor,
+			"Storage cluster is critically full",
+			fmt.Sprintf("Storage cluster utilization has crossed %d%%, writes will be blocked once it is full. Free up some space or expand the storage cluster immediately.", merged.FullPercent)),
+		newRule(cephOSDDownAlert, severityWarning,
+			"ceph_osd_up == 0", merged.OSDDownFor,
+			"OSD is down",
+			"OSD {{ $labels.ceph_daemon }} is down, the data it holds is served from the remaining replicas."),
+		newRule(cephMonQuorumLostAlert, severityCritical,
+			"count by (namespace) (ceph_mon_quorum_status == 1) <= count by (namespace) (ceph_mon_metadata) / 2", merged.MonQuorumLostFor,
+			"Storage cluster lost its monitor quorum",
+			"Less than a majority of the ceph monitors are in quorum, the storage cluster cannot serve IO."),
+		newRule(cephPGStuckAlert, severityCritical,
+			"sum by (namespace) (ceph_pg_total) - sum by (namespace) (ceph_pg_active) > 0", merged.PGStuckFor,
+			"Placement groups are stuck inactive",
+			"{{ $value }} placement groups are not active, the IO to the data they hold is blocked."),
+		newRule(cephSlowOpsAlert, severityWarning,
+			"ceph_healthcheck_slow_ops > 0", merged.SlowOpsFor,
+			"OSD requests are taking too long",
+			"{{ $value }} OSD requests are taking too long to complete."),
+	}
+}
+
+func (r *ManagedOCSReconciler) reconcileCephPrometheusRule() error {
+	r.Log.Info("Reconciling Ceph Prometheus Rule")
+
+	_, err := ctrl.CreateOrUpdate(r.ctx, r.Client, r.cephRule, func() error {
+		if err := r.own(r.cephRule); err != nil {
+			return err
+		}
+
+		// Like the DMS rule, the ceph rules are evaluated by our prometheus
+		if r.prometheusReconcileStrategy != v1.ReconcileStrategyNone {
+			utils.AddLabel(r.cephRule, monLabelKey, monLabelValue)
+			r.cephRule.Spec.Groups = []promv1.RuleGroup{{
+				Name:  "ceph-alerts",
+				Rules: newCephAlertRules(&r.managedOCS.Spec.Alerting.Ceph),
+			}}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+func findCephAlertRule(t *testing.T, rules []promv1.Rule, alert string) promv1.Rule {
+	for _, rule := range rules {
+		if rule.Alert == alert {
+			return rule
+		}
+	}
+	t.Fatalf("no rule for alert %s", alert)
+	return promv1.Rule{}
+}
+
+func TestCephAlertRulesDefaults(t *testing.T) {
+	rules := newCephAlertRules(&v1.CephAlertsSpec{})
+
+	expected := map[string]string{
+		cephClusterNearFullAlert: severityWarning,
+		cephClusterFullAlert:     severityCritical,
+		cephOSDDownAlert:         severityWarning,
+		cephMonQuorumLostAlert:   severityCritical,
+		cephPGStuckAlert:         severityCritical,
+		cephSlowOpsAlert:         severityWarning,
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, found %d", len(expected), len(rules))
+	}
+	for alert, severity := range expected {
+		rule := findCephAlertRule(t, rules, alert)
+		if rule.Labels["severity"] != severity {
+			t.Errorf("expected %s to have the %s severity, found %s", alert, severity, rule.Labels["severity"])
+		}
+		if rule.Annotations["runbook_url"] != defaultCephRunbookBaseURL+"/"+alert+".md" {
+			t.Errorf("unexpected runbook url for %s: %s", alert, rule.Annotations["runbook_url"])
+		}
+	}
+
+	if expr := findCephAlertRule(t, rules, cephClusterNearFullAlert).Expr.StrVal; !strings.HasSuffix(expr, "> 75") {
+		t.Errorf("expected the default near full threshold, found %s", expr)
+	}
+	if duration := findCephAlertRule(t, rules, cephOSDDownAlert).For; duration != "5m" {
+		t.Errorf("expected the default osd down duration, found %s", duration)
+	}
+}
+
+func TestCephAlertRulesOverrides(t *testing.T) {
+	rules := newCephAlertRules(&v1.CephAlertsSpec{
+		FullPercent:    90,
+		SlowOpsFor:     "2m",
+		RunbookBaseURL: "https://runbooks.example.com/storage/",
+	})
+
+	full := findCephAlertRule(t, rules, cephClusterFullAlert)
+	if !strings.HasSuffix(full.Expr.StrVal, "> 90") {
+		t.Errorf("expected the full threshold to be overridden, found %s", full.Expr.StrVal)
+	}
+	if full.Annotations["runbook_url"] != "https://runbooks.example.com/storage/"+cephClusterFullAlert+".md" {
+		t.Errorf("unexpected runbook url: %s", full.Annotations["runbook_url"])
+	}
+	if duration := findCephAlertRule(t, rules, cephSlowOpsAlert).For; duration != "2m" {
+		t.Errorf("expected the slow ops duration to be overridden, found %s", duration)
+	}
+	if expr := findCephAlertRule(t, rules, cephClusterNearFullAlert).Expr.StrVal; !strings.HasSuffix(expr, "> 75") {
+		t.Errorf("expected the near full threshold to keep its default, found %s", expr)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+const (
+	silenceCreatedBy = "ocs-osd-deployer"
+
+	silenceStateExpired = "expired"
+
+	alertmanagerRequestTimeout      = 10 * time.Second
+	maintenanceWindowsRetryInterval = time.Minute
+)
+
+// alertmanagerSilence is the silence representation of the alertmanager v2 API
+type alertmanagerSilence struct {
+	ID        string                     `json:"id,omitempty"`
+	Matchers  []alertmanagerMatcher      `json:"matchers"`
+	StartsAt  time.Time                  `json:"startsAt"`
+	EndsAt    time.Time                  `json:"endsAt"`
+	CreatedBy string                     `json:"createdBy"`
+	Comment   string                     `json:"comment"`
+	Status    *alertmanagerSilenceStatus `json:"status,omitempty"`
+}
+
+type alertmanagerMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+type alertmanagerSilenceStatus struct {
+	State string `json:"state"`
+}
+
+// alertmanagerClient calls the v2 API of the deployer alertmanager
+type alertmanagerClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+func newAlertmanagerClient(url string) *alertmanagerClient {
+	return &alertmanagerClient{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{Timeout: alertmanagerRequestTimeout},
+	}
+}
+
+func (c *alertmanagerClient) do(ctx context.Context, method string, path string, body interface{}, into interface{}) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return response.StatusCode, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("%s %s returned %d: %s", method, path, response.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if into != nil {
+		if err := json.Unmarshal(raw, into); err != nil {
+			return response.StatusCode, fmt.Errorf("unable to decode the response of %s %s: %v", method, path, err)
+		}
+	}
+	return response.StatusCode, nil
+}
+
+// getSilence returns nil when the silence does not exist
+func (c *alertmanagerClient) getSilence(ctx context.Context, id string) (*alertmanagerSilence, error) {
+	silence := &alertmanagerSilence{}
+	status, err := c.do(ctx, http.MethodGet, "/api/v2/silence/"+id, nil, silence)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	return silence, nil
+}
+
+// postSilence creates a silence, or updates it when the silence has an id, and returns the
+// id of the resulting silence
+func (c *alertmanagerClient) postSilence(ctx context.Context, silence *alertmanagerSilence) (string, error) {
+	response := struct {
+		SilenceID string `json:"silenceID"`
+	}{}
+	status, err := c.do(ctx, http.MethodPost, "/api/v2/silences", silence, &response)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusNotFound {
+		return "", fmt.Errorf("silence %s not found", silence.ID)
+	}
+	return response.SilenceID, nil
+}
+
+func (c *alertmanagerClient) deleteSilence(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v2/silence/"+id, nil, nil)
+	return err
+}
+
+// alertmanagerURL returns the address of the alertmanager API, which is served by the
+// governing service prometheus-operator creates for the alertmanager statefulset
+func (r *ManagedOCSReconciler) alertmanagerURL() string {
+	if r.AlertmanagerURL != "" {
+		return r.AlertmanagerURL
+	}
+	return fmt.Sprintf("http://alertmanager-operated.%s.svc:9093", r.namespace)
+}
+
+// maintenanceWindowOccurrence returns the current or next occurrence of a window. The end is
+// not after now when the window has no occurrence left
+func maintenanceWindowOccurrence(window *v1.MaintenanceWindowSpec, now time.Time) (time.Time, time.Time, error) {
+	if window.Schedule == "" {
+		if window.Start == nil || window.End == nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("either a start and an end or a schedule and a duration are required")
+		}
+		return window.Start.Time, window.End.Time, nil
+	}
+
+	if window.Duration == nil || window.Duration.Duration <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("a positive duration is required")
+	}
+	schedule, err := cron.ParseStandard(window.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid schedule: %v", err)
+	}
+	// The first start after now - duration is either the start of the ongoing occurrence
+	// or the start of the next one
+	start := schedule.Next(now.Add(-window.Duration.Duration))
+	return start, start.Add(window.Duration.Duration), nil
+}
+
+func newMaintenanceWindowSilence(window *v1.MaintenanceWindowSpec, start time.Time, end time.Time) *alertmanagerSilence {
+	silence := &alertmanagerSilence{
+		StartsAt:  start.UTC(),
+		EndsAt:    end.UTC(),
+		CreatedBy: silenceCreatedBy,
+		Comment:   window.Comment,
+	}
+	if silence.Comment == "" {
+		silence.Comment = fmt.Sprintf("Maintenance window %s", window.Name)
+	}
+	for _, matcher := range window.Matchers {
+		silence.Matchers = append(silence.Matchers, alertmanagerMatcher{
+			Name:    matcher.Name,
+			Value:   matcher.Value,
+			IsRegex: matcher.IsRegex,
+		})
+	}
+	return silence
+}
+
+// isSilenceUpToDate checks whether an existing silence still matches the desired one. The
+// alertmanager moves the start of silences created in the past to their creation time
+func isSilenceUpToDate(existing *alertmanagerSilence, desired *alertmanagerSilence, now time.Time) bool {
+	if existing.Status != nil && existing.Status.State == silenceStateExpired {
+		return false
+	}
+	started := desired.StartsAt.Before(now) && !existing.StartsAt.After(now)
+	if !(started || existing.StartsAt.Equal(desired.StartsAt)) || !existing.EndsAt.Equal(desired.EndsAt) ||
+		existing.Comment != desired.Comment || len(existing.Matchers) != len(desired.Matchers) {
+		return false
+	}
+	for i := range desired.Matchers {
+		if existing.Matchers[i] != desired.Matchers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileMaintenanceWindows keeps a silence for the current or next occurrence of every
+// maintenance window of the spec and expires the silences of the removed windows
+func (r *ManagedOCSReconciler) reconcileMaintenanceWindows() error {
+	windows := r.managedOCS.Spec.MaintenanceWindows
+	previous := map[string]v1.MaintenanceWindowStatus{}
+	for _, status := range r.managedOCS.Status.MaintenanceWindows {
+		previous[status.Name] = status
+	}
+	if len(windows) == 0 && len(previous) == 0 {
+		return nil
+	}
+
+	// Silences are kept by the alertmanager, wait for it before tracking them
+	if r.managedOCS.Status.Components.Alertmanager.State != v1.ComponentReady {
+		r.Log.Info("Alertmanager is not ready, skipping maintenance windows")
+		return nil
+	}
+	r.Log.Info("Reconciling maintenance windows")
+
+	ctx, cancel := context.WithTimeout(r.ctx, alertmanagerRequestTimeout)
+	defer cancel()
+	amClient := newAlertmanagerClient(r.alertmanagerURL())
+	now := time.Now()
+
+	// A failing window does not stop the others, the statuses of all windows are kept so
+	// that the next pass neither duplicates nor loses their silences
+	var firstErr error
+	recordErr := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	statuses := []v1.MaintenanceWindowStatus{}
+	for i := range windows {
+		window := &windows[i]
+		status := previous[window.Name]
+		delete(previous, window.Name)
+		status.Name = window.Name
+
+		previousID := status.SilenceID
+		if err := syncMaintenanceWindowSilence(ctx, amClient, window, &status, now); err != nil {
+			recordErr(err)
+		} else if status.SilenceID != previousID {
+			r.Log.Info("Created maintenance window silence", "window", window.Name, "silenceID", status.SilenceID)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for name, status := range previous {
+		if status.SilenceID == "" {
+			continue
+		}
+		existing, err := amClient.getSilence(ctx, status.SilenceID)
+		if err == nil && existing != nil && (existing.Status == nil || existing.Status.State != silenceStateExpired) {
+			if err = amClient.deleteSilence(ctx, status.SilenceID); err == nil {
+				r.Log.Info("Expired maintenance window silence", "window", name, "silenceID", status.SilenceID)
+			}
+		}
+		if err != nil {
+			// Keep the removed window until its silence is expired
+			recordErr(fmt.Errorf("unable to expire the silence of maintenance window %s: %v", name, err))
+			statuses = append(statuses, status)
+		}
+	}
+
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	r.managedOCS.Status.MaintenanceWindows = statuses
+	return firstErr
+}
+
+// syncMaintenanceWindowSilence keeps the silence of the current or next occurrence of a
+// maintenance window and records it in the window status. The status is left untouched
+// when the alertmanager cannot be reached
+func syncMaintenanceWindowSilence(ctx context.Context, amClient *alertmanagerClient, window *v1.MaintenanceWindowSpec,
+	status *v1.MaintenanceWindowStatus, now time.Time) error {
+	start, end, err := maintenanceWindowOccurrence(window, now)
+	if err != nil {
+		return newConfigurationError(reasonInvalidMaintenanceWindow,
+			"Invalid maintenance window %s: %v", window.Name, err)
+	}
+	if !end.After(now) {
+		return nil
+	}
+
+	desired := newMaintenanceWindowSilence(window, start, end)
+	var existing *alertmanagerSilence
+	if status.SilenceID != "" {
+		if existing, err = amClient.getSilence(ctx, status.SilenceID); err != nil {
+			return fmt.Errorf("unable to get the silence of maintenance window %s: %v", window.Name, err)
+		}
+	}
+
+	if existing == nil || !isSilenceUpToDate(existing, desired, now) {
+		// Updating a silence that is still pending or active keeps a single silence
+		// per window
+		if existing != nil && existing.Status != nil && existing.Status.State != silenceStateExpired {
+			desired.ID = existing.ID
+		}
+		id, err := amClient.postSilence(ctx, desired)
+		if err != nil {
+			return fmt.Errorf("unable to create the silence of maintenance window %s: %v", window.Name, err)
+		}
+		status.SilenceID = id
+	}
+	startsAt, endsAt := metav1.NewTime(start), metav1.NewTime(end)
+	status.StartsAt, status.EndsAt = &startsAt, &endsAt
+	return nil
+}
+
+// updateMaintenanceWindowsCondition reports the outcome of reconcileMaintenanceWindows
+// through the MaintenanceWindowsFailed condition
+func (r *ManagedOCSReconciler) updateMaintenanceWindowsCondition(err error) {
+	if err == nil {
+		r.setCondition(v1.ConditionMaintenanceWindowsFailed, metav1.ConditionFalse, reasonMaintenanceWindowsSynced, "")
+		return
+	}
+
+	r.Log.Error(err, "Unable to reconcile the maintenance windows")
+	reason := reasonAlertmanagerRequestFailed
+	var cfgErr *configurationError
+	if goerrors.As(err, &cfgErr) {
+		reason = cfgErr.reason
+	}
+	r.setCondition(v1.ConditionMaintenanceWindowsFailed, metav1.ConditionTrue, reason, err.Error())
+}
+
+// nextMaintenanceWindowCheck returns the time left until a recurring maintenance window
+// needs the silence of its next occurrence or the failed silence requests are retried,
+// zero when there is nothing to check
+func (r *ManagedOCSReconciler) nextMaintenanceWindowCheck() time.Duration {
+	recurring := map[string]bool{}
+	for _, window := range r.managedOCS.Spec.MaintenanceWindows {
+		recurring[window.Name] = window.Schedule != ""
+	}
+
+	// Retry the failed silence requests
+	var next time.Duration
+	if meta.IsStatusConditionTrue(r.managedOCS.Status.Conditions, v1.ConditionMaintenanceWindowsFailed) {
+		next = maintenanceWindowsRetryInterval
+	}
+	for _, status := range r.managedOCS.Status.MaintenanceWindows {
+		if !recurring[status.Name] || status.EndsAt == nil {
+			continue
+		}
+		left := time.Until(status.EndsAt.Time)
+		if left <= 0 {
+			left = time.Second
+		}
+		if next == 0 || left < next {
+			next = left
+		}
+	}
+	return next
+}
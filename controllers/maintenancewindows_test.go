@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+// fakeAlertmanager serves the silence endpoints of the alertmanager v2 API
+type fakeAlertmanager struct {
+	sync.Mutex
+	silences map[string]*alertmanagerSilence
+	posts    int
+	nextID   int
+
+	// failMatcherValue makes the posts of the silences with this matcher value fail
+	failMatcherValue string
+}
+
+func newFakeAlertmanager(t *testing.T) (*fakeAlertmanager, *httptest.Server) {
+	am := &fakeAlertmanager{silences: map[string]*alertmanagerSilence{}}
+	server := httptest.NewServer(am)
+	t.Cleanup(server.Close)
+	return am, server
+}
+
+func (am *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	am.Lock()
+	defer am.Unlock()
+
+	if req.Method == http.MethodPost && req.URL.Path == "/api/v2/silences" {
+		silence := &alertmanagerSilence{}
+		if err := json.NewDecoder(req.Body).Decode(silence); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		am.posts++
+		for _, matcher := range silence.Matchers {
+			if am.failMatcherValue != "" && matcher.Value == am.failMatcherValue {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if silence.ID == "" {
+			am.nextID++
+			silence.ID = fmt.Sprintf("silence-%d", am.nextID)
+		} else if am.silences[silence.ID] == nil {
+			http.Error(w, "silence not found", http.StatusNotFound)
+			return
+		}
+		silence.Status = &alertmanagerSilenceStatus{State: "pending"}
+		am.silences[silence.ID] = silence
+		_ = json.NewEncoder(w).Encode(map[string]string{"silenceID": silence.ID})
+		return
+	}
+
+	id := strings.TrimPrefix(req.URL.Path, "/api/v2/silence/")
+	silence := am.silences[id]
+	if silence == nil {
+		http.Error(w, "silence not found", http.StatusNotFound)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(silence)
+	case http.MethodDelete:
+		silence.Status.State = silenceStateExpired
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+}
+
+func newMaintenanceWindowsReconciler(url string, windows ...v1.MaintenanceWindowSpec) *ManagedOCSReconciler {
+	r := &ManagedOCSReconciler{
+		Log:             ctrl.Log.WithName("test"),
+		AlertmanagerURL: url,
+		ctx:             context.Background(),
+		managedOCS:      &v1.ManagedOCS{Spec: v1.ManagedOCSSpec{MaintenanceWindows: windows}},
+	}
+	r.managedOCS.Status.Components.Alertmanager.State = v1.ComponentReady
+	return r
+}
+
+func oneOffMaintenanceWindow(name string, start time.Time, end time.Time) v1.MaintenanceWindowSpec {
+	startTime, endTime := metav1.NewTime(start), metav1.NewTime(end)
+	return v1.MaintenanceWindowSpec{
+		Name:     name,
+		Start:    &startTime,
+		End:      &endTime,
+		Matchers: []v1.SilenceMatcher{{Name: "namespace", Value: "openshift-storage"}},
+	}
+}
+
+func TestMaintenanceWindowOccurrence(t *testing.T) {
+	window := &v1.MaintenanceWindowSpec{
+		Name:     "nightly",
+		Schedule: "0 2 * * *",
+		Duration: &metav1.Duration{Duration: 2 * time.Hour},
+	}
+
+	now := time.Date(2021, 3, 10, 3, 0, 0, 0, time.UTC)
+	start, end, err := maintenanceWindowOccurrence(window, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !start.Equal(time.Date(2021, 3, 10, 2, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2021, 3, 10, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the ongoing occurrence, found %v - %v", start, end)
+	}
+
+	now = time.Date(2021, 3, 10, 5, 0, 0, 0, time.UTC)
+	start, _, err = maintenanceWindowOccurrence(window, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !start.Equal(time.Date(2021, 3, 11, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the next occurrence, found %v", start)
+	}
+
+	window.Schedule = "not a schedule"
+	if _, _, err := maintenanceWindowOccurrence(window, now); err == nil {
+		t.Errorf("expected an invalid schedule error")
+	}
+}
+
+func TestMaintenanceWindowsCreateSilences(t *testing.T) {
+	am, server := newFakeAlertmanager(t)
+	now := time.Now().Truncate(time.Second)
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)),
+		oneOffMaintenanceWindow("past", now.Add(-2*time.Hour), now.Add(-time.Hour)),
+	)
+
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	statuses := r.managedOCS.Status.MaintenanceWindows
+	if len(statuses) != 2 {
+		t.Fatalf("expected a status for each window, found %v", statuses)
+	}
+	if statuses[0].SilenceID == "" || statuses[1].SilenceID != "" {
+		t.Fatalf("expected a silence for the upcoming window only, found %v", statuses)
+	}
+	silence := am.silences[statuses[0].SilenceID]
+	if !silence.StartsAt.Equal(now.Add(time.Hour)) || silence.Matchers[0].Value != "openshift-storage" {
+		t.Errorf("unexpected silence %v", silence)
+	}
+
+	// An unchanged window keeps its silence
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if am.posts != 1 {
+		t.Errorf("expected a single silence to be posted, found %d", am.posts)
+	}
+
+	// Changing the window updates its silence
+	r.managedOCS.Spec.MaintenanceWindows[0].Comment = "Cluster upgrade"
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if am.posts != 2 || r.managedOCS.Status.MaintenanceWindows[0].SilenceID != silence.ID {
+		t.Errorf("expected the silence to be updated in place")
+	}
+	if am.silences[silence.ID].Comment != "Cluster upgrade" {
+		t.Errorf("expected the updated comment, found %q", am.silences[silence.ID].Comment)
+	}
+}
+
+func TestMaintenanceWindowsKeepSilencesWhenAnotherWindowFails(t *testing.T) {
+	am, server := newFakeAlertmanager(t)
+	am.failMatcherValue = "failing"
+	now := time.Now()
+	failing := oneOffMaintenanceWindow("failing", now.Add(time.Hour), now.Add(2*time.Hour))
+	failing.Matchers[0].Value = "failing"
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)), failing)
+
+	if err := r.reconcileMaintenanceWindows(); err == nil {
+		t.Fatalf("expected an alertmanager request error")
+	}
+	statuses := r.managedOCS.Status.MaintenanceWindows
+	if len(statuses) != 2 || statuses[0].SilenceID == "" || statuses[1].SilenceID != "" {
+		t.Fatalf("expected the silence of the first window to be recorded, found %v", statuses)
+	}
+
+	// The retry only posts the silence of the failing window again
+	if err := r.reconcileMaintenanceWindows(); err == nil {
+		t.Fatalf("expected an alertmanager request error")
+	}
+	if am.posts != 3 || len(am.silences) != 1 {
+		t.Errorf("expected the first window silence to be posted once, found %d posts and %d silences", am.posts, len(am.silences))
+	}
+	if r.managedOCS.Status.MaintenanceWindows[0].SilenceID != statuses[0].SilenceID {
+		t.Errorf("expected the first window to keep its silence")
+	}
+}
+
+func TestMaintenanceWindowsExpireRemovedSilences(t *testing.T) {
+	am, server := newFakeAlertmanager(t)
+	now := time.Now()
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)))
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id := r.managedOCS.Status.MaintenanceWindows[0].SilenceID
+
+	r.managedOCS.Spec.MaintenanceWindows = nil
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if am.silences[id].Status.State != silenceStateExpired {
+		t.Errorf("expected the silence of the removed window to be expired")
+	}
+	if r.managedOCS.Status.MaintenanceWindows != nil {
+		t.Errorf("expected no maintenance window status, found %v", r.managedOCS.Status.MaintenanceWindows)
+	}
+}
+
+func TestMaintenanceWindowsWaitForAlertmanager(t *testing.T) {
+	am, server := newFakeAlertmanager(t)
+	now := time.Now()
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)))
+	r.managedOCS.Status.Components.Alertmanager.State = v1.ComponentPending
+
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if am.posts != 0 {
+		t.Errorf("expected no silence before the alertmanager is ready")
+	}
+}
+
+func TestMaintenanceWindowsRequeueRecurringWindows(t *testing.T) {
+	_, server := newFakeAlertmanager(t)
+	now := time.Now()
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)),
+		v1.MaintenanceWindowSpec{
+			Name:     "hourly",
+			Schedule: "@hourly",
+			Duration: &metav1.Duration{Duration: 10 * time.Minute},
+			Matchers: []v1.SilenceMatcher{{Name: "alertname", Value: "CephOSD.+", IsRegex: true}},
+		},
+	)
+	if err := r.reconcileMaintenanceWindows(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	next := r.nextMaintenanceWindowCheck()
+	if next <= 0 || next > 70*time.Minute {
+		t.Errorf("expected a requeue before the end of the hourly window, found %v", next)
+	}
+}
+
+func TestMaintenanceWindowsReportAlertmanagerFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	now := time.Now()
+	r := newMaintenanceWindowsReconciler(server.URL,
+		oneOffMaintenanceWindow("upgrade", now.Add(time.Hour), now.Add(2*time.Hour)))
+
+	err := r.reconcileMaintenanceWindows()
+	if err == nil {
+		t.Fatalf("expected an alertmanager request error")
+	}
+	r.updateMaintenanceWindowsCondition(err)
+	condition := meta.FindStatusCondition(r.managedOCS.Status.Conditions, v1.ConditionMaintenanceWindowsFailed)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonAlertmanagerRequestFailed {
+		t.Fatalf("expected the failure to be reported through a condition, found %v", condition)
+	}
+	if next := r.nextMaintenanceWindowCheck(); next != maintenanceWindowsRetryInterval {
+		t.Errorf("expected a retry after %v, found %v", maintenanceWindowsRetryInterval, next)
+	}
+
+	// The condition is cleared once the alertmanager recovers
+	_, recovered := newFakeAlertmanager(t)
+	r.AlertmanagerURL = recovered.URL
+	r.updateMaintenanceWindowsCondition(r.reconcileMaintenanceWindows())
+	if meta.IsStatusConditionTrue(r.managedOCS.Status.Conditions, v1.ConditionMaintenanceWindowsFailed) {
+		t.Errorf("expected the failure condition to be cleared")
+	}
+	if next := r.nextMaintenanceWindowCheck(); next != 0 {
+		t.Errorf("expected no retry, found %v", next)
+	}
+}
@@ -18,35 +18,56 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"gopkg.in/yaml.v2"
 
 	opv1a1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	controller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	sigsyaml "sigs.k8s.io/yaml"
 
 	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/go-logr/logr"
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	nbv1 "github.com/noobaa/noobaa-operator/v2/pkg/apis/noobaa/v1alpha1"
+	configv1 "github.com/openshift/api/config/v1"
 	ocsv1 "github.com/openshift/ocs-operator/pkg/apis/ocs/v1"
+	"github.com/openshift/ocs-operator/pkg/controller/defaults"
 	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
 	"github.com/openshift/ocs-osd-deployer/templates"
 	"github.com/openshift/ocs-osd-deployer/utils"
+	rookv1 "github.com/rook/rook/pkg/apis/rook.io/v1"
 )
 
 const (
@@ -54,27 +75,125 @@ const (
 )
 
 const (
-	managedOCSName               = "managedocs"
-	storageClusterName           = "ocs-storagecluster"
-	prometheusName               = "managed-ocs-prometheus"
-	alertmanagerName             = "managed-ocs-alertmanager"
-	alertmanagerConfigSecretName = "managed-ocs-alertmanager-config-secret"
-	dmsRuleName                  = "dms-monitor-rule"
-	storageClassSizeKey          = "size"
-	deviceSetName                = "default"
-	storageClassRbdName          = "ocs-storagecluster-ceph-rbd"
-	storageClassCephFSName       = "ocs-storagecluster-cephfs"
-	deployerCSVPrefix            = "ocs-osd-deployer"
-	monLabelKey                  = "app"
-	monLabelValue                = "managed-ocs"
+	managedOCSName                = "managedocs"
+	storageClusterName            = "ocs-storagecluster"
+	prometheusName                = "managed-ocs-prometheus"
+	alertmanagerName              = "managed-ocs-alertmanager"
+	alertmanagerConfigSecretName  = "managed-ocs-alertmanager-config-secret"
+	dmsRuleName                   = "dms-monitor-rule"
+	cephRuleName                  = "managed-ocs-ceph-rules"
+	storageClassSizeKey           = "size"
+	storageClassKey               = "storage-class"
+	encryptionKey                 = "encryption"
+	mcgEnabledKey                 = "enable-mcg"
+	noobaaName                    = "noobaa"
+	mcgReconcileStrategyManage    = "manage"
+	mcgReconcileStrategyIgnore    = "ignore"
+	nodePoolLabelKey              = "node-pool-label"
+	nodePoolTaintKey              = "node-pool-taint"
+	deviceSetName                 = "default"
+	storageClassRbdName           = "ocs-storagecluster-ceph-rbd"
+	storageClassCephFSName        = "ocs-storagecluster-cephfs"
+	deployerCSVPrefix             = "ocs-osd-deployer"
+	monLabelKey                   = "app"
+	monLabelValue                 = "managed-ocs"
+	scOverridesLabelKey           = "ocs.openshift.io/storagecluster-overrides"
+	pausedAnnotationKey           = "ocs.openshift.io/paused"
+	resourceProfilesConfigMapName = "managed-ocs-resource-profiles"
+	forceDeleteAnnotationKey      = "ocs.openshift.io/force-delete"
+	rookDeviceSetLabelKey         = "ceph.rook.io/DeviceSet"
+	infrastructureName            = "cluster"
+	zoneLabelKey                  = "topology.kubernetes.io/zone"
+	osdLabelValue                 = "rook-ceph-osd"
+	minZoneCount                  = 3
+
+	defaultStorageClassAnnotationKey = "storageclass.kubernetes.io/is-default-class"
+	maxLastErrorLength               = 1024
 )
 
+const (
+	reasonComponentsReady           = "ComponentsReady"
+	reasonComponentsNotReady        = "ComponentsNotReady"
+	reasonReconcileFailed           = "ReconcileFailed"
+	reasonReconcileSucceeded        = "ReconcileSucceeded"
+	reasonConfigurationValid        = "ConfigurationValid"
+	reasonAddonParamsSecretError    = "AddonParamsSecretError"
+	reasonInvalidSize               = "InvalidSize"
+	reasonInvalidOverrides          = "InvalidStorageClusterOverrides"
+	reasonPagerdutySecretError      = "PagerdutySecretError"
+	reasonDeadMansSnitchSecretError = "DeadMansSnitchSecretError"
+	reasonUninstallNotRequested     = "UninstallNotRequested"
+	reasonUninstallInProgress       = "UninstallInProgress"
+	reasonConsumerPVCsFound         = "ConsumerPVCsFound"
+	reasonPaused                    = "Paused"
+	reasonDeletionNotAuthorized     = "DeletionNotAuthorized"
+	reasonNodeResourcesSufficient   = "NodeResourcesSufficient"
+	reasonNodeResourcesInsufficient = "NodeResourcesInsufficient"
+	reasonStorageClassNotFound      = "StorageClassNotFound"
+	reasonZonesSufficient           = "ZonesSufficient"
+	reasonZonesInsufficient         = "ZonesInsufficient"
+	reasonInvalidEncryption         = "InvalidEncryption"
+	reasonEncryptionUnchanged       = "EncryptionUnchanged"
+	reasonEncryptionEnableRejected  = "EncryptionEnableNotSupported"
+	reasonEncryptionDisableRejected = "EncryptionDisableNotSupported"
+	reasonInvalidResourceProfiles   = "InvalidResourceProfiles"
+	reasonStorageClassConflict      = "StorageClassConflict"
+	reasonInvalidMCGEnablement      = "InvalidMCGEnablement"
+	reasonConsumerOBCsFound         = "ConsumerOBCsFound"
+	reasonInvalidNodePool           = "InvalidNodePool"
+	reasonAlertmanagerReceiverError = "AlertmanagerReceiverError"
+	reasonInvalidMaintenanceWindow  = "InvalidMaintenanceWindow"
+	reasonInvalidMonitoringFilter   = "InvalidMonitoringResourceFilter"
+	reasonMaintenanceWindowsSynced  = "MaintenanceWindowsSynced"
+	reasonAlertmanagerRequestFailed = "AlertmanagerRequestFailed"
+)
+
+// configurationError is returned by reconcile phases when they fail because of
+// missing or invalid configuration, as opposed to a failure talking to the cluster
+type configurationError struct {
+	reason  string
+	message string
+}
+
+func (e *configurationError) Error() string {
+	return e.message
+}
+
+func newConfigurationError(reason string, format string, args ...interface{}) error {
+	return &configurationError{
+		reason:  reason,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// phaseError annotates an error with the name of the reconcile phase that failed
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.phase, e.err)
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+func newPhaseError(phase string, err error) error {
+	return &phaseError{
+		phase: phase,
+		err:   err,
+	}
+}
+
 // ManagedOCSReconciler reconciles a ManagedOCS object
 type ManagedOCSReconciler struct {
 	Client             client.Client
 	UnrestrictedClient client.Client
 	Log                logr.Logger
 	Scheme             *runtime.Scheme
+	Recorder           record.EventRecorder
 
 	AddonParamSecretName         string
 	AddonConfigMapName           string
@@ -83,17 +202,30 @@ type ManagedOCSReconciler struct {
 	PagerdutySecretName          string
 	DeadMansSnitchSecretName     string
 
+	// AlertmanagerURL overrides the address of the deployer alertmanager API, which is
+	// used to silence alerts during maintenance windows
+	AlertmanagerURL string
+
 	ctx                      context.Context
 	managedOCS               *v1.ManagedOCS
 	storageCluster           *ocsv1.StorageCluster
+	noobaa                   *nbv1.NooBaa
 	prometheus               *promv1.Prometheus
 	dmsRule                  *promv1.PrometheusRule
+	cephRule                 *promv1.PrometheusRule
 	alertmanager             *promv1.Alertmanager
 	pagerdutySecret          *corev1.Secret
 	deadMansSnitchSecret     *corev1.Secret
 	alertmanagerConfigSecret *corev1.Secret
 	namespace                string
-	reconcileStrategy        v1.ReconcileStrategy
+
+	reconcileStrategy                   v1.ReconcileStrategy
+	prometheusReconcileStrategy         v1.ReconcileStrategy
+	alertmanagerReconcileStrategy       v1.ReconcileStrategy
+	alertmanagerConfigReconcileStrategy v1.ReconcileStrategy
+	monitoringLabelsReconcileStrategy   v1.ReconcileStrategy
+
+	uninstallRequested bool
 }
 
 // Add necessary rbac permissions for managedocs finalizer in order to set blockOwnerDeletion.
@@ -107,6 +239,13 @@ type ManagedOCSReconciler struct {
 // +kubebuilder:rbac:groups=operators.coreos.com,namespace=system,resources={subscriptions,clusterserviceversions},verbs=get;list;watch;delete
 // +kubebuilder:rbac:groups="apps",namespace=system,resources=statefulsets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list
+// +kubebuilder:rbac:groups="noobaa.io",namespace=system,resources=noobaas,verbs=get;list;watch
+// +kubebuilder:rbac:groups="objectbucket.io",resources=objectbucketclaims,verbs=get;list
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups="snapshot.storage.k8s.io",resources=volumesnapshotclasses,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups="config.openshift.io",resources=infrastructures,verbs=get
+// +kubebuilder:rbac:groups="",namespace=system,resources=events,verbs=create;patch
 
 // SetupWithManager creates an setup a ManagedOCSReconciler to work with the provided manager
 func (r *ManagedOCSReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -114,35 +253,65 @@ func (r *ManagedOCSReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		MaxConcurrentReconciles: 1,
 	}
 	managedOCSPredicates := builder.WithPredicates(
-		predicate.GenerationChangedPredicate{},
+		predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			// Annotation changes do not bump the generation, pausing or forcing
+			// deletion via annotations should still trigger a reconcile
+			predicate.Funcs{
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					oldAnnotations := e.MetaOld.GetAnnotations()
+					newAnnotations := e.MetaNew.GetAnnotations()
+					return oldAnnotations[pausedAnnotationKey] != newAnnotations[pausedAnnotationKey] ||
+						oldAnnotations[forceDeleteAnnotationKey] != newAnnotations[forceDeleteAnnotationKey]
+				},
+			},
+		),
 	)
 	secretPredicates := builder.WithPredicates(
 		predicate.NewPredicateFuncs(
 			func(meta metav1.Object, _ runtime.Object) bool {
 				name := meta.GetName()
+				_, isReceiver := meta.GetLabels()[alertmanagerReceiverLabelKey]
 				return name == r.AddonParamSecretName ||
 					name == r.PagerdutySecretName ||
-					name == r.DeadMansSnitchSecretName
+					name == r.DeadMansSnitchSecretName ||
+					isReceiver
 			},
 		),
 	)
-	addonDeleteConfigMapPredicates := builder.WithPredicates(
+	configMapPredicates := builder.WithPredicates(
 		predicate.NewPredicateFuncs(
 			func(meta metav1.Object, _ runtime.Object) bool {
+				labels := meta.GetLabels()
 				if meta.GetName() == r.AddonConfigMapName {
-					if _, ok := meta.GetLabels()[r.AddonConfigMapDeleteLabelKey]; ok {
+					if _, ok := labels[r.AddonConfigMapDeleteLabelKey]; ok {
 						return true
 					}
 				}
-				return false
+				if meta.GetName() == resourceProfilesConfigMapName {
+					return true
+				}
+				_, ok := labels[scOverridesLabelKey]
+				return ok
 			},
 		),
 	)
+	// The monitoring resources are checked against the allow and deny lists of the spec
+	// whenever they are created or their labels change
+	monResourcesUpdated := func(e event.UpdateEvent) bool {
+		labels := e.MetaNew.GetLabels()
+		return labels[monLabelKey] != monLabelValue || !reflect.DeepEqual(e.MetaOld.GetLabels(), labels)
+	}
 	monResourcesPredicates := builder.WithPredicates(
+		predicate.Funcs{
+			UpdateFunc: monResourcesUpdated,
+		},
+	)
+	osdVolumeClaimPredicates := builder.WithPredicates(
 		predicate.NewPredicateFuncs(
 			func(meta metav1.Object, _ runtime.Object) bool {
-				labels := meta.GetLabels()
-				return labels == nil || labels[monLabelKey] != monLabelValue
+				_, ok := meta.GetLabels()[rookDeviceSetLabelKey]
+				return ok
 			},
 		),
 	)
@@ -155,15 +324,21 @@ func (r *ManagedOCSReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			},
 		),
 	)
-	prometheusRulesPredicates := builder.WithPredicates(
+	noobaaPredicates := builder.WithPredicates(
 		predicate.NewPredicateFuncs(
 			func(meta metav1.Object, _ runtime.Object) bool {
-				name := meta.GetName()
-				labels := meta.GetLabels()
-				return labels == nil || labels[monLabelKey] != monLabelValue || name == dmsRuleName
+				return meta.GetName() == noobaaName
 			},
 		),
 	)
+	prometheusRulesPredicates := builder.WithPredicates(
+		predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				name := e.MetaNew.GetName()
+				return name == dmsRuleName || name == cephRuleName || monResourcesUpdated(e)
+			},
+		},
+	)
 	enqueueManangedOCSRequest := handler.EnqueueRequestsFromMapFunc{
 		ToRequests: handler.ToRequestsFunc(
 			func(obj handler.MapObject) []reconcile.Request {
@@ -196,7 +371,7 @@ func (r *ManagedOCSReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(
 			&source.Kind{Type: &corev1.ConfigMap{}},
 			&enqueueManangedOCSRequest,
-			addonDeleteConfigMapPredicates,
+			configMapPredicates,
 		).
 		Watches(
 			&source.Kind{Type: &promv1.PodMonitor{}},
@@ -218,6 +393,16 @@ func (r *ManagedOCSReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			&enqueueManangedOCSRequest,
 			monStatefulSetPredicates,
 		).
+		Watches(
+			&source.Kind{Type: &corev1.PersistentVolumeClaim{}},
+			&enqueueManangedOCSRequest,
+			osdVolumeClaimPredicates,
+		).
+		Watches(
+			&source.Kind{Type: &nbv1.NooBaa{}},
+			&enqueueManangedOCSRequest,
+			noobaaPredicates,
+		).
 
 		// Create the controller
 		Complete(r)
@@ -245,11 +430,13 @@ func (r *ManagedOCSReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	if err != nil {
 		r.Log.Error(err, "An error was encountered during reconcilePhases")
 	}
+	r.updateReconcileStatus(err)
+	r.updateReconcileConditions(err)
 
 	// Ensure status is updated once even on failed reconciles
 	var statusErr error
 	if r.managedOCS.UID != "" {
-		statusErr = r.Client.Status().Update(r.ctx, r.managedOCS)
+		statusErr = r.updateStatus()
 	}
 
 	// Reconcile errors have priority to status update errors
@@ -274,6 +461,10 @@ func (r *ManagedOCSReconciler) initReconciler(req ctrl.Request) {
 	r.storageCluster.Name = storageClusterName
 	r.storageCluster.Namespace = r.namespace
 
+	r.noobaa = &nbv1.NooBaa{}
+	r.noobaa.Name = noobaaName
+	r.noobaa.Namespace = r.namespace
+
 	r.prometheus = &promv1.Prometheus{}
 	r.prometheus.Name = prometheusName
 	r.prometheus.Namespace = r.namespace
@@ -282,6 +473,10 @@ func (r *ManagedOCSReconciler) initReconciler(req ctrl.Request) {
 	r.dmsRule.Name = dmsRuleName
 	r.dmsRule.Namespace = r.namespace
 
+	r.cephRule = &promv1.PrometheusRule{}
+	r.cephRule.Name = cephRuleName
+	r.cephRule.Namespace = r.namespace
+
 	r.alertmanager = &promv1.Alertmanager{}
 	r.alertmanager.Name = alertmanagerName
 	r.alertmanager.Namespace = r.namespace
@@ -305,11 +500,27 @@ func (r *ManagedOCSReconciler) reconcilePhases() (reconcile.Result, error) {
 	// We are checking the uninstallation condition before getting the component status
 	// to mitigate scenarios where changes to the component status occurs while the uninstallation logic is running.
 	initiateUninstall := r.checkUninstallCondition()
+	r.uninstallRequested = initiateUninstall
 	// Update the status of the components
 	r.updateComponentStatus()
 
+	// A paused deployer keeps reporting status but does not write to any managed resource
+	r.updatePausedStatus()
+	if r.managedOCS.Status.Paused {
+		r.Log.Info("ManagedOCS is paused, skipping reconcile of managed resources")
+		if initiateUninstall {
+			r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionTrue, reasonPaused,
+				"Uninstall was requested but the deployer is paused")
+		}
+		return ctrl.Result{}, nil
+	}
+
 	if !r.managedOCS.DeletionTimestamp.IsZero() {
-		if r.verifyComponentsDoNotExist() {
+		componentsDeleted, err := r.verifyComponentsDoNotExist()
+		if err != nil {
+			return ctrl.Result{}, newPhaseError("verifyComponentsDoNotExist", err)
+		}
+		if componentsDeleted {
 			r.Log.Info("removing finalizer from the ManagedOCS resource")
 			r.managedOCS.SetFinalizers(utils.Remove(r.managedOCS.GetFinalizers(), ManagedOCSFinalizer))
 			if err := r.Client.Update(r.ctx, r.managedOCS); err != nil {
@@ -317,8 +528,19 @@ func (r *ManagedOCSReconciler) reconcilePhases() (reconcile.Result, error) {
 			}
 			r.Log.Info("finallizer removed successfully")
 
+		} else if !initiateUninstall && !isForceDeleteRequested(r.managedOCS) {
+			// Tearing down the components outside of the add-on uninstall flow would lose customer data
+			message := fmt.Sprintf(
+				"ManagedOCS was deleted without an add-on uninstall request, refusing to delete the components. "+
+					"Set the %s annotation to \"true\" to force the deletion", forceDeleteAnnotationKey)
+			r.Log.Info(message)
+			r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionTrue, reasonDeletionNotAuthorized, message)
+			if r.Recorder != nil {
+				r.Recorder.Event(r.managedOCS, corev1.EventTypeWarning, reasonDeletionNotAuthorized, message)
+			}
+
 		} else if err := r.deleteComponents(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("deleteComponents", err)
 		}
 
 	} else if r.managedOCS.UID != "" {
@@ -330,58 +552,138 @@ func (r *ManagedOCSReconciler) reconcilePhases() (reconcile.Result, error) {
 			}
 		}
 
-		// Find the effective reconcile strategy
-		r.reconcileStrategy = v1.ReconcileStrategyStrict
-		if strings.EqualFold(string(r.managedOCS.Spec.ReconcileStrategy), string(v1.ReconcileStrategyNone)) {
-			r.reconcileStrategy = v1.ReconcileStrategyNone
-		}
+		// Find the effective reconcile strategies
+		components := &r.managedOCS.Spec.Components
+		r.reconcileStrategy = effectiveReconcileStrategy(
+			components.StorageCluster.ReconcileStrategy,
+			r.managedOCS.Spec.ReconcileStrategy,
+		)
+		r.prometheusReconcileStrategy = effectiveReconcileStrategy(components.Prometheus.ReconcileStrategy, "")
+		r.alertmanagerReconcileStrategy = effectiveReconcileStrategy(components.Alertmanager.ReconcileStrategy, "")
+		r.alertmanagerConfigReconcileStrategy = effectiveReconcileStrategy(components.AlertmanagerConfig.ReconcileStrategy, "")
+		r.monitoringLabelsReconcileStrategy = effectiveReconcileStrategy(components.MonitoringLabels.ReconcileStrategy, "")
 
 		// Reconcile the different owned resources
 		if err := r.reconcileStorageCluster(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcileStorageCluster", err)
+		}
+		if err := r.reconcileStorageClasses(); err != nil {
+			return ctrl.Result{}, newPhaseError("reconcileStorageClasses", err)
 		}
 		if err := r.reconcilePrometheus(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcilePrometheus", err)
 		}
 		if err := r.reconcileAlertmanager(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcileAlertmanager", err)
 		}
 		if err := r.reconcileAlertmanagerConfigSecret(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcileAlertmanagerConfigSecret", err)
 		}
+		// An unreachable alertmanager must not hold back the other phases, the failure is
+		// reported through a condition and retried
+		r.updateMaintenanceWindowsCondition(r.reconcileMaintenanceWindows())
 		if err := r.reconcileMonitoringResources(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcileMonitoringResources", err)
 		}
 		if err := r.reconcileDMSPrometheusRule(); err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, newPhaseError("reconcileDMSPrometheusRule", err)
+		}
+		if err := r.reconcileCephPrometheusRule(); err != nil {
+			return ctrl.Result{}, newPhaseError("reconcileCephPrometheusRule", err)
 		}
 
 		r.managedOCS.Status.ReconcileStrategy = r.reconcileStrategy
 
 		// Check if we need and can uninstall
-		if initiateUninstall && r.areComponentsReadyForUninstall() {
+		if !initiateUninstall {
+			r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionFalse, reasonUninstallNotRequested, "")
+
+		} else if !r.areComponentsReadyForUninstall() {
+			r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionTrue, reasonComponentsNotReady,
+				"Uninstall was requested but not all components are ready")
+
+		} else {
 			found, err := r.findOCSVolumeClaims()
 			if err != nil {
-				return ctrl.Result{}, err
+				return ctrl.Result{}, newPhaseError("findOCSVolumeClaims", err)
 			}
 			if found {
 				r.Log.Info("Found consumer PVCs using OCS storageclasses, cannot proceed on uninstallation")
+				r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionTrue, reasonConsumerPVCsFound,
+					"Uninstall was requested but there are PVCs using OCS storageclasses")
 				return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
 			}
 
+			found, err = r.findOCSObjectBucketClaims()
+			if err != nil {
+				return ctrl.Result{}, newPhaseError("findOCSObjectBucketClaims", err)
+			}
+			if found {
+				r.Log.Info("Found consumer OBCs using OCS storageclasses, cannot proceed on uninstallation")
+				r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionTrue, reasonConsumerOBCsFound,
+					"Uninstall was requested but there are OBCs using OCS storageclasses")
+				return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+			}
+
+			r.setCondition(v1.ConditionUninstallBlocked, metav1.ConditionFalse, reasonUninstallInProgress, "")
 			r.Log.Info("starting OCS uninstallation - deleting managedocs")
 			if err := r.delete(r.managedOCS); err != nil && !errors.IsNotFound(err) {
 				return ctrl.Result{}, fmt.Errorf("unable to delete managedocs: %v", err)
 			}
 		}
 
+		result := ctrl.Result{}
+
+		// Node changes are not watched, recheck a held scale-up periodically
+		if meta.IsStatusConditionTrue(r.managedOCS.Status.Conditions, v1.ConditionInsufficientCapacity) {
+			result.RequeueAfter = time.Minute
+		}
+
+		// Recurring maintenance windows need a new silence once the current one ends
+		if next := r.nextMaintenanceWindowCheck(); next > 0 && (result.RequeueAfter == 0 || next < result.RequeueAfter) {
+			result.RequeueAfter = next
+		}
+		return result, nil
+
 	} else if initiateUninstall {
-		return ctrl.Result{}, r.removeOLMComponents()
+		if err := r.removeOLMComponents(); err != nil {
+			return ctrl.Result{}, newPhaseError("removeOLMComponents", err)
+		}
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// updatePausedStatus reflects the pause state of the ManagedOCS resource in its status
+func (r *ManagedOCSReconciler) updatePausedStatus() {
+	status := &r.managedOCS.Status
+	paused := r.managedOCS.UID != "" && (r.managedOCS.Spec.Paused ||
+		strings.EqualFold(r.managedOCS.GetAnnotations()[pausedAnnotationKey], "true"))
+
+	if paused && !status.Paused {
+		now := metav1.Now()
+		status.PausedSince = &now
+	} else if !paused {
+		status.PausedSince = nil
+	}
+	status.Paused = paused
+}
+
+// effectiveReconcileStrategy returns the strategy to use for a component, using fallback
+// when the component does not specify one. Unrecognized values are treated as strict
+func effectiveReconcileStrategy(strategy v1.ReconcileStrategy, fallback v1.ReconcileStrategy) v1.ReconcileStrategy {
+	if strategy == "" {
+		strategy = fallback
+	}
+	if strings.EqualFold(string(strategy), string(v1.ReconcileStrategyNone)) {
+		return v1.ReconcileStrategyNone
+	}
+	if strings.EqualFold(string(strategy), string(v1.ReconcileStrategyMerge)) {
+		return v1.ReconcileStrategyMerge
+	}
+	return v1.ReconcileStrategyStrict
+}
+
 func (r *ManagedOCSReconciler) updateComponentStatus() {
 	// Getting the status of the StorageCluster component.
 	scStatus := &r.managedOCS.Status.Components.StorageCluster
@@ -398,6 +700,25 @@ func (r *ManagedOCSReconciler) updateComponentStatus() {
 		scStatus.State = v1.ComponentUnknown
 	}
 
+	// Getting the status of the NooBaa component. NooBaa is created by ocs-operator
+	// and only expected to exist when the multi-cloud gateway is managed. Without a
+	// StorageCluster the NooBaa state follows the StorageCluster state
+	nbStatus := &r.managedOCS.Status.Components.NooBaa
+	if scStatus.State == v1.ComponentNotFound || scStatus.State == v1.ComponentUnknown {
+		nbStatus.State = scStatus.State
+	} else if err := r.get(r.noobaa); err == nil {
+		if r.noobaa.Status.Phase == nbv1.SystemPhaseReady {
+			nbStatus.State = v1.ComponentReady
+		} else {
+			nbStatus.State = v1.ComponentPending
+		}
+	} else if errors.IsNotFound(err) {
+		nbStatus.State = v1.ComponentNotFound
+	} else {
+		r.Log.V(-1).Info("error getting NooBaa, setting compoment status to Unknown")
+		nbStatus.State = v1.ComponentUnknown
+	}
+
 	// Getting the status of the Prometheus component.
 	promStatus := &r.managedOCS.Status.Components.Prometheus
 	if err := r.get(r.prometheus); err == nil {
@@ -449,15 +770,109 @@ func (r *ManagedOCSReconciler) updateComponentStatus() {
 		r.Log.V(-1).Info("error getting Alertmanager, setting compoment status to Unknown")
 		amStatus.State = v1.ComponentUnknown
 	}
+
+	// Summarize the component states as the Available and Progressing conditions
+	notReady := []string{}
+	if scStatus.State != v1.ComponentReady {
+		notReady = append(notReady, fmt.Sprintf("StorageCluster is %s", scStatus.State))
+	}
+	if isMultiCloudGatewayManaged(r.storageCluster) && nbStatus.State != v1.ComponentReady {
+		notReady = append(notReady, fmt.Sprintf("NooBaa is %s", nbStatus.State))
+	}
+	if promStatus.State != v1.ComponentReady {
+		notReady = append(notReady, fmt.Sprintf("Prometheus is %s", promStatus.State))
+	}
+	if amStatus.State != v1.ComponentReady {
+		notReady = append(notReady, fmt.Sprintf("Alertmanager is %s", amStatus.State))
+	}
+	if len(notReady) == 0 {
+		r.setCondition(v1.ConditionAvailable, metav1.ConditionTrue, reasonComponentsReady, "All components are ready")
+		r.setCondition(v1.ConditionProgressing, metav1.ConditionFalse, reasonComponentsReady, "All components are ready")
+	} else {
+		message := strings.Join(notReady, ", ")
+		r.setCondition(v1.ConditionAvailable, metav1.ConditionFalse, reasonComponentsNotReady, message)
+		r.setCondition(v1.ConditionProgressing, metav1.ConditionTrue, reasonComponentsNotReady, message)
+	}
+}
+
+// updateReconcileStatus records the generation and outcome of the current reconcile
+func (r *ManagedOCSReconciler) updateReconcileStatus(err error) {
+	now := metav1.Now()
+	status := &r.managedOCS.Status
+	status.ObservedGeneration = r.managedOCS.Generation
+	status.LastReconcileTime = &now
+	if err == nil {
+		status.LastSuccessfulReconcileTime = &now
+		status.LastError = ""
+	} else {
+		status.LastError = err.Error()
+		if len(status.LastError) > maxLastErrorLength {
+			// Cut on a rune boundary to keep the status valid UTF-8
+			cut := maxLastErrorLength
+			for cut > 0 && !utf8.RuneStart(status.LastError[cut]) {
+				cut--
+			}
+			status.LastError = status.LastError[:cut]
+		}
+	}
+}
+
+// updateStatus writes the status of the current reconcile. The status written by the previous
+// reconcile can still be missing from the cache, conflicts are retried on the latest resource
+func (r *ManagedOCSReconciler) updateStatus() error {
+	status := r.managedOCS.Status.DeepCopy()
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		err := r.Client.Status().Update(r.ctx, r.managedOCS)
+		if errors.IsConflict(err) {
+			if err := r.get(r.managedOCS); err != nil {
+				return err
+			}
+			r.managedOCS.Status = *status.DeepCopy()
+		}
+		return err
+	})
 }
 
-func (r *ManagedOCSReconciler) verifyComponentsDoNotExist() bool {
+// updateReconcileConditions sets the Degraded and ConfigurationInvalid conditions
+// based on the outcome of the reconcile phases
+func (r *ManagedOCSReconciler) updateReconcileConditions(err error) {
+	if err == nil {
+		r.setCondition(v1.ConditionDegraded, metav1.ConditionFalse, reasonReconcileSucceeded, "")
+		r.setCondition(v1.ConditionConfigurationInvalid, metav1.ConditionFalse, reasonConfigurationValid, "")
+		return
+	}
+
+	r.setCondition(v1.ConditionDegraded, metav1.ConditionTrue, reasonReconcileFailed, err.Error())
+	var cfgErr *configurationError
+	if goerrors.As(err, &cfgErr) {
+		r.setCondition(v1.ConditionConfigurationInvalid, metav1.ConditionTrue, cfgErr.reason, cfgErr.message)
+	}
+}
+
+func (r *ManagedOCSReconciler) setCondition(conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&r.managedOCS.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: r.managedOCS.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (r *ManagedOCSReconciler) verifyComponentsDoNotExist() (bool, error) {
 	subComponent := r.managedOCS.Status.Components
 
-	if subComponent.StorageCluster.State == v1.ComponentNotFound {
-		return true
+	if subComponent.StorageCluster.State != v1.ComponentNotFound {
+		return false, nil
 	}
-	return false
+
+	// The additional storage classes are cluster scoped and are not garbage collected
+	// along with the ManagedOCS resource
+	exist, err := r.ownedStorageClassesExist()
+	if err != nil {
+		return false, err
+	}
+	return !exist, nil
 }
 
 func (r *ManagedOCSReconciler) deleteComponents() error {
@@ -465,6 +880,11 @@ func (r *ManagedOCSReconciler) deleteComponents() error {
 	if err := r.delete(r.storageCluster); err != nil && !errors.IsNotFound(err) {
 		return fmt.Errorf("unable to delete storagecluster: %v", err)
 	}
+
+	r.Log.Info("deleting additional storageclasses")
+	if err := r.deleteStorageClasses(nil, nil); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -476,11 +896,34 @@ func (r *ManagedOCSReconciler) reconcileStorageCluster() error {
 			return err
 		}
 
-		// Handle only strict mode reconciliation
-		if r.reconcileStrategy == v1.ReconcileStrategyStrict {
+		// Handle only strict and merge mode reconciliation
+		if r.reconcileStrategy != v1.ReconcileStrategyNone {
+			addonParams, err := r.getAddonParams()
+			if err != nil {
+				return err
+			}
+
 			// Get an instance of the desired state
 			desired := templates.StorageClusterTemplate.DeepCopy()
-			if err := r.updateStorageClusterFromAddonParamsSecret(desired); err != nil {
+			// The resource profile is applied before the overrides so it can be overridden
+			if err := r.applyResourceProfile(desired, addonParams); err != nil {
+				return err
+			}
+			// The node pool is applied before the overrides so they can further restrict the
+			// storage nodes, and before the capacity checks that look at the nodes of the pool
+			if err := r.updateStorageClusterNodePool(desired, addonParams); err != nil {
+				return err
+			}
+			if r.reconcileStrategy == v1.ReconcileStrategyMerge {
+				if err := r.applyStorageClusterOverrides(desired); err != nil {
+					return err
+				}
+			}
+			// Add-on parameters are applied after the overrides so sizing stays managed
+			if err := r.updateStorageClusterFromAddonParams(desired, addonParams); err != nil {
+				return err
+			}
+			if err := r.updateStorageClusterPlacement(desired); err != nil {
 				return err
 			}
 
@@ -494,29 +937,254 @@ func (r *ManagedOCSReconciler) reconcileStorageCluster() error {
 		return err
 	}
 
+	r.updateCapacityStatus()
+
 	return nil
 }
 
-func (r *ManagedOCSReconciler) updateStorageClusterFromAddonParamsSecret(sc *ocsv1.StorageCluster) error {
-	// The addon param secret will contain the capacity of the cluster in Ti
-	// size = 1,  creates a cluster of 1 Ti capacity
-	// size = 2,  creates a cluster of 2 Ti capacity etc
+// applyStorageClusterOverrides patches the desired storage cluster with the overrides
+// provided on the ManagedOCS resource
+func (r *ManagedOCSReconciler) applyStorageClusterOverrides(sc *ocsv1.StorageCluster) error {
+	overrides := r.managedOCS.Spec.Components.StorageCluster.Overrides
+	if overrides == nil {
+		return nil
+	}
+
+	patch := overrides.Patch
+	if ref := overrides.ConfigMapKeyRef; ref != nil {
+		optional := ref.Optional != nil && *ref.Optional
+		configMap := &corev1.ConfigMap{}
+		configMap.Name = ref.Name
+		configMap.Namespace = r.namespace
+		if err := r.get(configMap); err != nil {
+			if errors.IsNotFound(err) && optional {
+				return nil
+			}
+			return newConfigurationError(reasonInvalidOverrides,
+				"Unable to get storage cluster overrides configmap %v: %v", ref.Name, err)
+		}
+		value, ok := configMap.Data[ref.Key]
+		if !ok {
+			if optional {
+				return nil
+			}
+			return newConfigurationError(reasonInvalidOverrides,
+				"Storage cluster overrides configmap %v does not contain a %v entry", ref.Name, ref.Key)
+		}
+		patch = value
+	}
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+
+	// Patches can be provided as either yaml or json
+	patchJSON, err := sigsyaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return newConfigurationError(reasonInvalidOverrides, "Unable to decode storage cluster overrides: %v", err)
+	}
+	original, err := json.Marshal(sc)
+	if err != nil {
+		return fmt.Errorf("Unable to encode storage cluster: %v", err)
+	}
+
+	var patched []byte
+	switch overrides.PatchType {
+	case "", v1.PatchTypeStrategicMerge:
+		patched, err = strategicMergeStorageCluster(original, patchJSON)
+	case v1.PatchTypeJSON:
+		var jsonPatch jsonpatch.Patch
+		if jsonPatch, err = jsonpatch.DecodePatch(patchJSON); err == nil {
+			patched, err = jsonPatch.Apply(original)
+		}
+	default:
+		return newConfigurationError(reasonInvalidOverrides, "Unknown storage cluster overrides patch type: %v", overrides.PatchType)
+	}
+	if err != nil {
+		return newConfigurationError(reasonInvalidOverrides, "Unable to apply storage cluster overrides: %v", err)
+	}
 
+	result := &ocsv1.StorageCluster{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return newConfigurationError(reasonInvalidOverrides, "Unable to decode patched storage cluster: %v", err)
+	}
+	sc.Spec = result.Spec
+
+	return nil
+}
+
+// strategicMergeStorageCluster applies a strategic merge patch to an encoded storage cluster
+func strategicMergeStorageCluster(original []byte, patch []byte) ([]byte, error) {
+	originalMap := map[string]interface{}{}
+	if err := json.Unmarshal(original, &originalMap); err != nil {
+		return nil, err
+	}
+	patchMap := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &patchMap); err != nil {
+		return nil, err
+	}
+	schema := mapValuePatchMeta{strategicpatch.PatchMetaFromStruct{T: reflect.TypeOf(ocsv1.StorageCluster{})}}
+	patchedMap, err := strategicpatch.StrategicMergeMapPatchUsingLookupPatchMeta(originalMap, patchMap, schema)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(patchedMap)
+}
+
+// mapValuePatchMeta looks up the patch metadata of go structs like PatchMetaFromStruct, and
+// also of the values of go maps, e.g. the resources of the storage cluster spec. Every key
+// of a map shares the patch metadata of the map value type
+type mapValuePatchMeta struct {
+	strategicpatch.PatchMetaFromStruct
+}
+
+func (m mapValuePatchMeta) LookupPatchMetadataForStruct(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	t := m.T
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Map {
+		return mapValuePatchMeta{strategicpatch.PatchMetaFromStruct{T: t.Elem()}}, strategicpatch.PatchMeta{}, nil
+	}
+	subschema, patchMeta, err := m.PatchMetaFromStruct.LookupPatchMetadataForStruct(key)
+	if err != nil {
+		return nil, strategicpatch.PatchMeta{}, err
+	}
+	return mapValuePatchMeta{subschema.(strategicpatch.PatchMetaFromStruct)}, patchMeta, nil
+}
+
+func (m mapValuePatchMeta) LookupPatchMetadataForSlice(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	subschema, patchMeta, err := m.PatchMetaFromStruct.LookupPatchMetadataForSlice(key)
+	if err != nil {
+		return nil, strategicpatch.PatchMeta{}, err
+	}
+	return mapValuePatchMeta{subschema.(strategicpatch.PatchMetaFromStruct)}, patchMeta, nil
+}
+
+func (r *ManagedOCSReconciler) getAddonParams() (map[string][]byte, error) {
 	addonParamSecret := &corev1.Secret{}
 	addonParamSecret.Name = r.AddonParamSecretName
 	addonParamSecret.Namespace = r.namespace
 	if err := r.get(addonParamSecret); err != nil {
 		// Do not create the StorageCluster if the we fail to get the addon param secret
-		return fmt.Errorf("Failed to get the addon param secret, Secret Name: %v", r.AddonParamSecretName)
+		return nil, newConfigurationError(reasonAddonParamsSecretError,
+			"Failed to get the addon param secret, Secret Name: %v", r.AddonParamSecretName)
 	}
-	addonParams := addonParamSecret.Data
+	return addonParamSecret.Data, nil
+}
 
-	sizeAsString := string(addonParams[storageClassSizeKey])
-	r.Log.Info("Requested add-on settings", storageClassSizeKey, sizeAsString)
-	desiredDeviceSetCount, err := strconv.Atoi(sizeAsString)
+// applyResourceProfile sets the resources of the storage cluster daemons from the resource
+// profile matching the cluster size. The size is the larger of the requested and the applied
+// device set count, as downscaling is not supported. The profile thresholds can be tuned
+// through the resource profiles ConfigMap
+func (r *ManagedOCSReconciler) applyResourceProfile(sc *ocsv1.StorageCluster, addonParams map[string][]byte) error {
+	ds := findDeviceSet(sc)
+	if ds == nil {
+		return fmt.Errorf("could not find default device set on stroage cluster")
+	}
+	count, err := sizeToDeviceSetCount(string(addonParams[storageClassSizeKey]), ds)
+	if err != nil {
+		return newConfigurationError(reasonInvalidSize, "Invalid storage cluster size value: %v", err)
+	}
+	if curr := findDeviceSet(r.storageCluster); curr != nil && curr.Count > count {
+		count = curr.Count
+	}
+	volumeSize := ds.DataPVCTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+	usableCapacity := resource.NewQuantity(volumeSize.Value()*int64(count), volumeSize.Format)
+
+	thresholds, err := r.getResourceProfileThresholds()
 	if err != nil {
-		return fmt.Errorf("Invalid storage cluster size value: %v", sizeAsString)
+		return err
+	}
+	profileName := templates.ResourceProfileSmall
+	for _, name := range []string{templates.ResourceProfileMedium, templates.ResourceProfileLarge} {
+		if threshold := thresholds[name]; usableCapacity.Cmp(threshold) >= 0 {
+			profileName = name
+		}
+	}
+	r.Log.Info("Applying resource profile", "Profile", profileName, "UsableCapacity", usableCapacity.String())
+	r.managedOCS.Status.ResourceProfile = profileName
+
+	profile := templates.ResourceProfiles[profileName]
+	sc.Spec.Resources = map[string]corev1.ResourceRequirements{
+		"mds": *profile.Mds.DeepCopy(),
+		"mgr": *profile.Mgr.DeepCopy(),
+		"mon": *profile.Mon.DeepCopy(),
+	}
+	for index := range sc.Spec.StorageDeviceSets {
+		sc.Spec.StorageDeviceSets[index].Resources = *profile.Osd.DeepCopy()
+	}
+	return nil
+}
+
+// getResourceProfileThresholds returns the minimal usable capacity of each resource profile,
+// with the defaults replaced by the values found in the resource profiles ConfigMap
+func (r *ManagedOCSReconciler) getResourceProfileThresholds() (map[string]resource.Quantity, error) {
+	thresholds := map[string]resource.Quantity{}
+	for name, value := range defaultResourceProfileThresholds {
+		thresholds[name] = resource.MustParse(value)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	configMap.Name = resourceProfilesConfigMapName
+	configMap.Namespace = r.namespace
+	if err := r.get(configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return thresholds, nil
+		}
+		return nil, fmt.Errorf("unable to get the resource profiles configmap: %v", err)
 	}
+	for name := range thresholds {
+		value, ok := configMap.Data[name]
+		if !ok {
+			continue
+		}
+		threshold, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, newConfigurationError(reasonInvalidResourceProfiles,
+				"Invalid %s resource profile threshold in ConfigMap %s: %v", name, resourceProfilesConfigMapName, value)
+		}
+		thresholds[name] = threshold
+	}
+	return thresholds, nil
+}
+
+// defaultResourceProfileThresholds holds the minimal usable capacity of each resource profile,
+// smaller clusters use the small profile
+var defaultResourceProfileThresholds = map[string]string{
+	templates.ResourceProfileMedium: "4Ti",
+	templates.ResourceProfileLarge:  "20Ti",
+}
+
+func findDeviceSet(sc *ocsv1.StorageCluster) *ocsv1.StorageDeviceSet {
+	for index := range sc.Spec.StorageDeviceSets {
+		ds := &sc.Spec.StorageDeviceSets[index]
+		if ds.Name == deviceSetName {
+			return ds
+		}
+	}
+	return nil
+}
+
+func (r *ManagedOCSReconciler) updateStorageClusterFromAddonParams(sc *ocsv1.StorageCluster, addonParams map[string][]byte) error {
+	// The addon params will contain the capacity of the cluster, either
+	// as a plain number of Ti or as a quantity
+	// size = 1,  creates a cluster of 1 Ti capacity
+	// size = 2,  creates a cluster of 2 Ti capacity etc
+	// size = 4Ti, creates a cluster of 4 Ti capacity
+
+	if err := r.updateStorageClusterStorageClass(sc, string(addonParams[storageClassKey])); err != nil {
+		return err
+	}
+	if err := r.updateStorageClusterEncryption(sc, addonParams); err != nil {
+		return err
+	}
+	if err := r.updateStorageClusterMultiCloudGateway(sc, addonParams); err != nil {
+		return err
+	}
+
+	sizeAsString := string(addonParams[storageClassSizeKey])
+	r.Log.Info("Requested add-on settings", storageClassSizeKey, sizeAsString)
+	r.managedOCS.Status.Capacity.RequestedSize = sizeAsString
 
 	// Get the storage device set count of the current storage cluster
 	currDeviceSetCount := 0
@@ -540,18 +1208,688 @@ func (r *ManagedOCSReconciler) updateStorageClusterFromAddonParamsSecret(sc *ocs
 		return fmt.Errorf("could not find default device set on stroage cluster")
 	}
 
+	desiredDeviceSetCount, err := sizeToDeviceSetCount(sizeAsString, ds)
+	if err != nil {
+		return newConfigurationError(reasonInvalidSize, "Invalid storage cluster size value: %v", err)
+	}
+
 	// Prevent downscaling by comparing count from secret and count from storage cluster
 	r.Log.Info("Setting storage device set count", "Current", currDeviceSetCount, "New", desiredDeviceSetCount)
 	if currDeviceSetCount <= desiredDeviceSetCount {
-		ds.Count = desiredDeviceSetCount
+		count, err := r.nextDeviceSetCount(sc, ds, currDeviceSetCount, desiredDeviceSetCount)
+		if err != nil {
+			return err
+		}
+		ds.Count = count
+		r.managedOCS.Status.Capacity.DownscaleRejected = false
 	} else {
 		r.Log.V(-1).Info("Requested storage device set count will result in downscaling, which is not supported. Skipping")
 		ds.Count = currDeviceSetCount
+		r.managedOCS.Status.Capacity.DownscaleRejected = true
+		r.managedOCS.Status.Capacity.ScaleUp = v1.ScaleUpStatus{}
 	}
 
 	return nil
 }
 
+// updateStorageClusterStorageClass sets the storage class of the mon and device set volumes
+// that were not set by the storage cluster overrides. The storage class requested through
+// the add-on parameters takes precedence over the one selected for the platform. The volume
+// templates of an existing storage cluster cannot change, so the storage class in use is kept
+func (r *ManagedOCSReconciler) updateStorageClusterStorageClass(sc *ocsv1.StorageCluster, requested string) error {
+	storageClassName := requested
+	if inUse := getDeviceSetStorageClass(r.storageCluster); inUse != "" {
+		if storageClassName != "" && storageClassName != inUse {
+			r.Log.V(-1).Info("Changing the storage class of an existing storage cluster is not supported. Skipping",
+				"Current", inUse, "Requested", storageClassName)
+		}
+		storageClassName = inUse
+	} else if storageClassName != "" {
+		// The requested storage class is only used for new storage clusters
+		exists, err := r.storageClassExists(storageClassName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return newConfigurationError(reasonStorageClassNotFound,
+				"Storage class %q requested through the add-on parameters does not exist", storageClassName)
+		}
+	} else {
+		selected, err := r.selectStorageClass()
+		if err != nil {
+			return err
+		}
+		storageClassName = selected
+	}
+
+	if sc.Spec.MonPVCTemplate != nil && sc.Spec.MonPVCTemplate.Spec.StorageClassName == nil {
+		sc.Spec.MonPVCTemplate.Spec.StorageClassName = &storageClassName
+	}
+	for index := range sc.Spec.StorageDeviceSets {
+		pvcSpec := &sc.Spec.StorageDeviceSets[index].DataPVCTemplate.Spec
+		if pvcSpec.StorageClassName == nil {
+			pvcSpec.StorageClassName = &storageClassName
+		}
+	}
+	return nil
+}
+
+// updateStorageClusterEncryption enables encryption at rest on new storage clusters when
+// requested through the add-on parameters. The encryption of existing storage clusters
+// cannot change, a request to change it is refused and reported through a condition
+func (r *ManagedOCSReconciler) updateStorageClusterEncryption(sc *ocsv1.StorageCluster, addonParams map[string][]byte) error {
+	value, requested := addonParams[encryptionKey]
+	enable := false
+	if requested {
+		var err error
+		if enable, err = strconv.ParseBool(string(value)); err != nil {
+			return newConfigurationError(reasonInvalidEncryption, "Invalid encryption value: %v", string(value))
+		}
+	}
+
+	// A storage cluster that was not created yet has no encryption to keep
+	if r.storageCluster.UID == "" {
+		sc.Spec.Encryption.Enable = enable
+		r.setCondition(v1.ConditionEncryptionChangeRejected, metav1.ConditionFalse, reasonEncryptionUnchanged, "")
+		return nil
+	}
+
+	current := r.storageCluster.Spec.Encryption.Enable
+	sc.Spec.Encryption.Enable = current
+	switch {
+	case !requested || enable == current:
+		r.setCondition(v1.ConditionEncryptionChangeRejected, metav1.ConditionFalse, reasonEncryptionUnchanged, "")
+	case current:
+		r.Log.V(-1).Info("Disabling encryption of an existing storage cluster is not supported. Skipping")
+		r.setCondition(v1.ConditionEncryptionChangeRejected, metav1.ConditionTrue, reasonEncryptionDisableRejected,
+			"Encryption cannot be disabled on an existing storage cluster")
+	default:
+		r.Log.V(-1).Info("Enabling encryption of an existing storage cluster is not supported. Skipping")
+		r.setCondition(v1.ConditionEncryptionChangeRejected, metav1.ConditionTrue, reasonEncryptionEnableRejected,
+			"Encryption can only be enabled when the storage cluster is created")
+	}
+	return nil
+}
+
+// updateStorageClusterMultiCloudGateway sets the multi-cloud gateway reconcile strategy of the
+// storage cluster as requested through the add-on parameters. Once the multi-cloud gateway is
+// managed it stays managed, as disabling it would orphan the NooBaa system and its buckets
+func (r *ManagedOCSReconciler) updateStorageClusterMultiCloudGateway(sc *ocsv1.StorageCluster, addonParams map[string][]byte) error {
+	enable := false
+	if value, requested := addonParams[mcgEnabledKey]; requested {
+		var err error
+		if enable, err = strconv.ParseBool(string(value)); err != nil {
+			return newConfigurationError(reasonInvalidMCGEnablement, "Invalid multi-cloud gateway enablement value: %v", string(value))
+		}
+	}
+
+	if !enable && r.storageCluster.UID != "" && isMultiCloudGatewayManaged(r.storageCluster) {
+		r.Log.V(-1).Info("Disabling the multi-cloud gateway of an existing storage cluster is not supported. Skipping")
+		enable = true
+	}
+
+	if sc.Spec.MultiCloudGateway == nil {
+		sc.Spec.MultiCloudGateway = &ocsv1.MultiCloudGatewaySpec{}
+	}
+	if enable {
+		sc.Spec.MultiCloudGateway.ReconcileStrategy = mcgReconcileStrategyManage
+	} else {
+		sc.Spec.MultiCloudGateway.ReconcileStrategy = mcgReconcileStrategyIgnore
+	}
+	return nil
+}
+
+// isMultiCloudGatewayManaged checks whether the multi-cloud gateway of the storage cluster was
+// switched to managed. The deployer always sets the reconcile strategy, a missing one comes from
+// an edit of the storage cluster and is reverted like any other
+func isMultiCloudGatewayManaged(sc *ocsv1.StorageCluster) bool {
+	mcg := sc.Spec.MultiCloudGateway
+	return mcg != nil && strings.EqualFold(mcg.ReconcileStrategy, mcgReconcileStrategyManage)
+}
+
+// cephDaemonPlacementKeys lists the storage cluster placements of the Ceph daemons
+var cephDaemonPlacementKeys = []rookv1.KeyType{"all", "mon", "osd", "mds", "rgw"}
+
+// updateStorageClusterNodePool restricts the storage cluster to the nodes of the node pool
+// requested through the add-on parameters, on top of the label selector of the template, and
+// lets the Ceph daemons tolerate the taint of the node pool. The label is given as key=value or
+// key, the taint as key=value:effect or key:effect
+func (r *ManagedOCSReconciler) updateStorageClusterNodePool(sc *ocsv1.StorageCluster, addonParams map[string][]byte) error {
+	if value := string(addonParams[nodePoolLabelKey]); value != "" {
+		requirement, err := parseNodePoolLabel(value)
+		if err != nil {
+			return newConfigurationError(reasonInvalidNodePool, "Invalid node pool label %q: %v", value, err)
+		}
+		if sc.Spec.LabelSelector == nil {
+			sc.Spec.LabelSelector = &metav1.LabelSelector{}
+		}
+		sc.Spec.LabelSelector.MatchExpressions = append(sc.Spec.LabelSelector.MatchExpressions, requirement)
+	}
+
+	value := string(addonParams[nodePoolTaintKey])
+	if value == "" {
+		return nil
+	}
+	toleration, err := parseNodePoolTaint(value)
+	if err != nil {
+		return newConfigurationError(reasonInvalidNodePool, "Invalid node pool taint %q: %v", value, err)
+	}
+	if sc.Spec.Placement == nil {
+		sc.Spec.Placement = rookv1.PlacementSpec{}
+	}
+	for _, key := range cephDaemonPlacementKeys {
+		placement, ok := sc.Spec.Placement[key]
+		if !ok {
+			// Setting a placement replaces the ocs-operator default, start from it
+			defaultPlacement := defaults.DaemonPlacements[string(key)]
+			defaultPlacement.DeepCopyInto(&placement)
+		}
+		placement.Tolerations = appendToleration(placement.Tolerations, toleration)
+		sc.Spec.Placement[key] = placement
+	}
+	for index := range sc.Spec.StorageDeviceSets {
+		placement := &sc.Spec.StorageDeviceSets[index].Placement
+		placement.Tolerations = appendToleration(placement.Tolerations, toleration)
+	}
+	return nil
+}
+
+func parseNodePoolLabel(value string) (metav1.LabelSelectorRequirement, error) {
+	parts := strings.SplitN(value, "=", 2)
+	requirement := metav1.LabelSelectorRequirement{Key: parts[0]}
+	if errs := validation.IsQualifiedName(parts[0]); len(errs) > 0 {
+		return requirement, goerrors.New(strings.Join(errs, ", "))
+	}
+	if len(parts) == 1 {
+		requirement.Operator = metav1.LabelSelectorOpExists
+		return requirement, nil
+	}
+	if errs := validation.IsValidLabelValue(parts[1]); len(errs) > 0 {
+		return requirement, goerrors.New(strings.Join(errs, ", "))
+	}
+	requirement.Operator = metav1.LabelSelectorOpIn
+	requirement.Values = []string{parts[1]}
+	return requirement, nil
+}
+
+func parseNodePoolTaint(value string) (corev1.Toleration, error) {
+	toleration := corev1.Toleration{}
+	separator := strings.LastIndex(value, ":")
+	if separator == -1 {
+		return toleration, fmt.Errorf("missing taint effect")
+	}
+	toleration.Effect = corev1.TaintEffect(value[separator+1:])
+	switch toleration.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return toleration, fmt.Errorf("unsupported taint effect %q", toleration.Effect)
+	}
+
+	parts := strings.SplitN(value[:separator], "=", 2)
+	if errs := validation.IsQualifiedName(parts[0]); len(errs) > 0 {
+		return toleration, goerrors.New(strings.Join(errs, ", "))
+	}
+	toleration.Key = parts[0]
+	if len(parts) == 1 {
+		toleration.Operator = corev1.TolerationOpExists
+		return toleration, nil
+	}
+	if errs := validation.IsValidLabelValue(parts[1]); len(errs) > 0 {
+		return toleration, goerrors.New(strings.Join(errs, ", "))
+	}
+	toleration.Operator = corev1.TolerationOpEqual
+	toleration.Value = parts[1]
+	return toleration, nil
+}
+
+func appendToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) []corev1.Toleration {
+	for i := range tolerations {
+		if tolerations[i].MatchToleration(&toleration) {
+			return tolerations
+		}
+	}
+	return append(tolerations, toleration)
+}
+
+// platformStorageClasses lists the storage classes suitable for OSD volumes on each
+// platform, in order of preference
+var platformStorageClasses = map[configv1.PlatformType][]string{
+	configv1.AWSPlatformType:   {"gp3", "gp2"},
+	configv1.GCPPlatformType:   {"pd-ssd"},
+	configv1.AzurePlatformType: {"managed-premium"},
+}
+
+// provisionerPlatforms maps the provisioners of the platform default storage classes
+// to their platform
+var provisionerPlatforms = map[string]configv1.PlatformType{
+	"kubernetes.io/aws-ebs":    configv1.AWSPlatformType,
+	"ebs.csi.aws.com":          configv1.AWSPlatformType,
+	"kubernetes.io/gce-pd":     configv1.GCPPlatformType,
+	"pd.csi.storage.gke.io":    configv1.GCPPlatformType,
+	"kubernetes.io/azure-disk": configv1.AzurePlatformType,
+	"disk.csi.azure.com":       configv1.AzurePlatformType,
+}
+
+// selectStorageClass picks a storage class for the platform of the cluster. The platform is
+// taken from the cluster Infrastructure resource or, failing that, guessed from the provisioner
+// of the default storage class. The default storage class is used when the platform has no
+// suitable storage class
+func (r *ManagedOCSReconciler) selectStorageClass() (string, error) {
+	platform, err := r.getInfrastructurePlatform()
+	if err != nil {
+		return "", err
+	}
+
+	storageClassList := &storagev1.StorageClassList{}
+	if err := r.UnrestrictedClient.List(r.ctx, storageClassList); err != nil {
+		return "", fmt.Errorf("unable to list storage classes: %v", err)
+	}
+	var defaultStorageClass *storagev1.StorageClass
+	existing := map[string]bool{}
+	for i := range storageClassList.Items {
+		storageClass := &storageClassList.Items[i]
+		existing[storageClass.Name] = true
+		if storageClass.Annotations[defaultStorageClassAnnotationKey] == "true" {
+			defaultStorageClass = storageClass
+		}
+	}
+	if platform == "" && defaultStorageClass != nil {
+		platform = provisionerPlatforms[defaultStorageClass.Provisioner]
+	}
+
+	for _, name := range platformStorageClasses[platform] {
+		if existing[name] {
+			r.Log.Info("Selected storage class", "Platform", platform, "StorageClass", name)
+			return name, nil
+		}
+	}
+	if defaultStorageClass != nil {
+		r.Log.Info("No storage class found for the platform, using the default storage class",
+			"Platform", platform, "StorageClass", defaultStorageClass.Name)
+		return defaultStorageClass.Name, nil
+	}
+	return "", newConfigurationError(reasonStorageClassNotFound,
+		"Unable to find a storage class for platform %q, set the %s add-on parameter", platform, storageClassKey)
+}
+
+// getInfrastructurePlatform returns the platform from the cluster Infrastructure resource, or an
+// empty platform type when the resource is not available
+func (r *ManagedOCSReconciler) getInfrastructurePlatform() (configv1.PlatformType, error) {
+	infrastructure := &configv1.Infrastructure{}
+	key := client.ObjectKey{Name: infrastructureName}
+	if err := r.UnrestrictedClient.Get(r.ctx, key, infrastructure); err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to get the cluster infrastructure: %v", err)
+	}
+	if infrastructure.Status.PlatformStatus != nil && infrastructure.Status.PlatformStatus.Type != "" {
+		return infrastructure.Status.PlatformStatus.Type, nil
+	}
+	return infrastructure.Status.Platform, nil
+}
+
+func (r *ManagedOCSReconciler) storageClassExists(name string) (bool, error) {
+	storageClass := &storagev1.StorageClass{}
+	if err := r.UnrestrictedClient.Get(r.ctx, client.ObjectKey{Name: name}, storageClass); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to get storage class %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// getDeviceSetStorageClass returns the storage class of the default device set volumes
+func getDeviceSetStorageClass(sc *ocsv1.StorageCluster) string {
+	for index := range sc.Spec.StorageDeviceSets {
+		ds := &sc.Spec.StorageDeviceSets[index]
+		if ds.Name == deviceSetName && ds.DataPVCTemplate.Spec.StorageClassName != nil {
+			return *ds.DataPVCTemplate.Spec.StorageClassName
+		}
+	}
+	return ""
+}
+
+// nextDeviceSetCount limits a scale-up to the configured maximum step. A step is only taken
+// once the storage cluster is ready and the OSD volumes of the previous step are bound, and
+// only if the storage nodes can fit the resulting storage cluster
+func (r *ManagedOCSReconciler) nextDeviceSetCount(sc *ocsv1.StorageCluster, ds *ocsv1.StorageDeviceSet, currCount int, desiredCount int) (int, error) {
+	scaleUp := &r.managedOCS.Status.Capacity.ScaleUp
+	scaleUp.TargetDeviceSetCount = desiredCount
+
+	if currCount == desiredCount {
+		r.setCondition(v1.ConditionInsufficientCapacity, metav1.ConditionFalse, reasonNodeResourcesSufficient, "")
+		scaleUp.InProgress = false
+		scaleUp.Message = ""
+		return desiredCount, nil
+	}
+
+	nextCount := desiredCount
+	if maxStep := r.managedOCS.Spec.Components.StorageCluster.MaxDeviceSetCountStep; maxStep > 0 {
+		// A storage cluster without device sets has nothing to wait for
+		if currCount > 0 {
+			ready, message, err := r.isDeviceSetScaleUpSettled(currCount, ds.Replica)
+			if err != nil {
+				return 0, err
+			}
+			if !ready {
+				r.Log.Info("Waiting before the next storage device set scale-up step", "reason", message)
+				scaleUp.InProgress = true
+				scaleUp.Message = message
+				return currCount, nil
+			}
+		}
+		if currCount+maxStep < desiredCount {
+			nextCount = currCount + maxStep
+		}
+	}
+
+	missing, err := r.findMissingNodeResources(sc, ds, nextCount)
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) > 0 {
+		message := fmt.Sprintf("Scaling the storage device set count to %d is held, the storage nodes are missing %s",
+			nextCount, formatResourceList(missing))
+		r.Log.Info(message)
+		r.setCondition(v1.ConditionInsufficientCapacity, metav1.ConditionTrue, reasonNodeResourcesInsufficient, message)
+		scaleUp.InProgress = true
+		scaleUp.Message = message
+		return currCount, nil
+	}
+	r.setCondition(v1.ConditionInsufficientCapacity, metav1.ConditionFalse, reasonNodeResourcesSufficient, "")
+
+	r.Log.Info("Scaling up storage device set count", "Current", currCount, "Next", nextCount, "Target", desiredCount)
+	scaleUp.InProgress = nextCount < desiredCount
+	scaleUp.Message = ""
+	if scaleUp.InProgress {
+		scaleUp.Message = fmt.Sprintf("Scaling storage device set count from %d to %d", currCount, nextCount)
+	}
+	return nextCount, nil
+}
+
+// findMissingNodeResources compares the allocatable resources of the nodes matching the
+// storage cluster label selector with the resources requested by the storage cluster
+// components for the given device set count. The returned list holds the missing amounts
+func (r *ManagedOCSReconciler) findMissingNodeResources(sc *ocsv1.StorageCluster, ds *ocsv1.StorageDeviceSet, count int) (corev1.ResourceList, error) {
+	nodeList, err := r.listStorageNodes(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	allocatable := corev1.ResourceList{}
+	for i := range nodeList.Items {
+		addResourceList(allocatable, nodeList.Items[i].Status.Allocatable, 1)
+	}
+
+	requested := corev1.ResourceList{}
+	addResourceList(requested, ds.Resources.Requests, count*ds.Replica)
+	for component, replicas := range storageClusterComponentReplicas {
+		addResourceList(requested, sc.Spec.Resources[component].Requests, replicas)
+	}
+
+	missing := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		want := requested[name].DeepCopy()
+		have := allocatable[name]
+		if want.Cmp(have) > 0 {
+			want.Sub(have)
+			missing[name] = want
+		}
+	}
+	return missing, nil
+}
+
+// listStorageNodes lists the nodes matching the storage cluster label selector
+func (r *ManagedOCSReconciler) listStorageNodes(sc *ocsv1.StorageCluster) (*corev1.NodeList, error) {
+	nodeList := &corev1.NodeList{}
+	listOptions := []client.ListOption{}
+	if sc.Spec.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sc.Spec.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storagecluster label selector: %v", err)
+		}
+		listOptions = append(listOptions, client.MatchingLabelsSelector{Selector: selector})
+	}
+	if err := r.UnrestrictedClient.List(r.ctx, nodeList, listOptions...); err != nil {
+		return nil, fmt.Errorf("unable to list nodes: %v", err)
+	}
+	return nodeList, nil
+}
+
+// updateStorageClusterPlacement spreads the OSDs of the device sets across the zones of the
+// storage nodes. Placement rules that were set by the storage cluster overrides are kept
+func (r *ManagedOCSReconciler) updateStorageClusterPlacement(sc *ocsv1.StorageCluster) error {
+	nodeList, err := r.listStorageNodes(sc)
+	if err != nil {
+		return err
+	}
+	zoneSet := map[string]bool{}
+	for i := range nodeList.Items {
+		if zone := nodeList.Items[i].Labels[zoneLabelKey]; zone != "" {
+			zoneSet[zone] = true
+		}
+	}
+	zones := []string{}
+	for zone := range zoneSet {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	r.managedOCS.Status.Zones = zones
+	r.managedOCS.Status.StorageNodeCount = len(nodeList.Items)
+
+	if len(zones) < minZoneCount {
+		r.setCondition(v1.ConditionInsufficientZones, metav1.ConditionTrue, reasonZonesInsufficient,
+			fmt.Sprintf("The storage nodes span %d zone(s), at least %d are required to spread the replicas across zones",
+				len(zones), minZoneCount))
+	} else {
+		r.setCondition(v1.ConditionInsufficientZones, metav1.ConditionFalse, reasonZonesSufficient, "")
+	}
+
+	// Without topology information the placement is left to the scheduler
+	if len(zones) == 0 {
+		return nil
+	}
+
+	whenUnsatisfiable := corev1.DoNotSchedule
+	if len(zones) < minZoneCount {
+		whenUnsatisfiable = corev1.ScheduleAnyway
+	}
+	osdSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "app",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{osdLabelValue},
+		}},
+	}
+	for index := range sc.Spec.StorageDeviceSets {
+		placement := &sc.Spec.StorageDeviceSets[index].Placement
+		if placement.NodeAffinity == nil {
+			// ocs-operator only applies the storage cluster label selector to device sets
+			// without a placement, keep the OSDs on the storage nodes
+			requirements := []corev1.NodeSelectorRequirement{{
+				Key:      zoneLabelKey,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   zones,
+			}}
+			if sc.Spec.LabelSelector != nil {
+				requirements = append(requirements, labelSelectorToNodeSelectorRequirements(sc.Spec.LabelSelector)...)
+			}
+			placement.NodeAffinity = &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: requirements,
+					}},
+				},
+			}
+		}
+		if placement.PodAntiAffinity == nil {
+			placement.PodAntiAffinity = &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: osdSelector,
+						TopologyKey:   zoneLabelKey,
+					},
+				}},
+			}
+		}
+		if placement.TopologySpreadConstraints == nil {
+			placement.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       zoneLabelKey,
+				WhenUnsatisfiable: whenUnsatisfiable,
+				LabelSelector:     osdSelector,
+			}}
+		}
+	}
+	return nil
+}
+
+func labelSelectorToNodeSelectorRequirements(selector *metav1.LabelSelector) []corev1.NodeSelectorRequirement {
+	requirements := []corev1.NodeSelectorRequirement{}
+	keys := []string{}
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{selector.MatchLabels[key]},
+		})
+	}
+	for _, expression := range selector.MatchExpressions {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      expression.Key,
+			Operator: corev1.NodeSelectorOperator(expression.Operator),
+			Values:   expression.Values,
+		})
+	}
+	return requirements
+}
+
+// storageClusterComponentReplicas is the number of pods OCS runs for each of the
+// storage cluster components, on top of the OSDs
+var storageClusterComponentReplicas = map[string]int{
+	"mon": 3,
+	"mgr": 1,
+	"mds": 2,
+}
+
+func addResourceList(total corev1.ResourceList, resources corev1.ResourceList, times int) {
+	for name, quantity := range resources {
+		sum := total[name]
+		for i := 0; i < times; i++ {
+			sum.Add(quantity)
+		}
+		total[name] = sum
+	}
+}
+
+func formatResourceList(resources corev1.ResourceList) string {
+	parts := []string{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if quantity, ok := resources[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s: %s", name, quantity.String()))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isDeviceSetScaleUpSettled checks that the storage cluster is ready and that all of the
+// OSD volumes of the default device set are bound
+func (r *ManagedOCSReconciler) isDeviceSetScaleUpSettled(count int, replica int) (bool, string, error) {
+	if r.storageCluster.Status.Phase != "Ready" {
+		return false, "Waiting for the storagecluster to become Ready", nil
+	}
+
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(r.ctx, pvcList, client.InNamespace(r.namespace), client.HasLabels{rookDeviceSetLabelKey}); err != nil {
+		return false, "", fmt.Errorf("unable to list OSD pvcs: %v", err)
+	}
+	// OCS names the rook device sets after the storage device set followed by a replica index
+	boundCount := 0
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if strings.HasPrefix(pvc.Labels[rookDeviceSetLabelKey], deviceSetName+"-") &&
+			pvc.Status.Phase == corev1.ClaimBound {
+			boundCount++
+		}
+	}
+	if expected := count * replica; boundCount < expected {
+		return false, fmt.Sprintf("Waiting for OSD pvcs to be Bound (%d/%d)", boundCount, expected), nil
+	}
+	return true, "", nil
+}
+
+// sizeToDeviceSetCount converts the size add-on parameter into a device set count. A plain
+// integer is the device set count itself, kept for backward compatibility. A quantity must
+// be a multiple of the device set volume size, the error of any other quantity lists the
+// closest accepted sizes
+func sizeToDeviceSetCount(size string, ds *ocsv1.StorageDeviceSet) (int, error) {
+	if count, err := strconv.Atoi(size); err == nil {
+		if count < 0 {
+			return 0, fmt.Errorf("%q must not be negative", size)
+		}
+		return count, nil
+	}
+
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither an integer nor a quantity", size)
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("%q must not be negative", size)
+	}
+	volumeSize, ok := ds.DataPVCTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok || volumeSize.Sign() <= 0 {
+		return 0, fmt.Errorf("the storage device set does not specify a volume size")
+	}
+	if quantity.Value()%volumeSize.Value() != 0 {
+		count := quantity.Value() / volumeSize.Value()
+		above := resource.NewQuantity(volumeSize.Value()*(count+1), volumeSize.Format)
+		accepted := above.String()
+		if count > 0 {
+			below := resource.NewQuantity(volumeSize.Value()*count, volumeSize.Format)
+			accepted = fmt.Sprintf("%v or %v", below.String(), accepted)
+		}
+		return 0, fmt.Errorf("%q is not a multiple of the %v device set volume size, "+
+			"use a multiple such as %v, or a plain device set count", size, volumeSize.String(), accepted)
+	}
+	return int(quantity.Value() / volumeSize.Value()), nil
+}
+
+// updateCapacityStatus reports the capacity applied through the default storage device set
+func (r *ManagedOCSReconciler) updateCapacityStatus() {
+	capacity := &r.managedOCS.Status.Capacity
+	capacity.DeviceSetCount = 0
+	capacity.Replica = 0
+	capacity.RawCapacity = nil
+	capacity.UsableCapacity = nil
+
+	for index := range r.storageCluster.Spec.StorageDeviceSets {
+		ds := &r.storageCluster.Spec.StorageDeviceSets[index]
+		if ds.Name != deviceSetName {
+			continue
+		}
+		capacity.DeviceSetCount = ds.Count
+		capacity.Replica = ds.Replica
+
+		volumeSize, ok := ds.DataPVCTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+		if !ok {
+			break
+		}
+		usable := resource.NewQuantity(volumeSize.Value()*int64(ds.Count), volumeSize.Format)
+		raw := resource.NewQuantity(usable.Value()*int64(ds.Replica), volumeSize.Format)
+		capacity.UsableCapacity = usable
+		capacity.RawCapacity = raw
+		break
+	}
+}
+
 func (r *ManagedOCSReconciler) reconcilePrometheus() error {
 	r.Log.Info("Reconciling Prometheus")
 
@@ -560,9 +1898,12 @@ func (r *ManagedOCSReconciler) reconcilePrometheus() error {
 			return err
 		}
 
-		desired := templates.PrometheusTemplate.DeepCopy()
-		r.prometheus.ObjectMeta.Labels = map[string]string{monLabelKey: monLabelValue}
-		r.prometheus.Spec = desired.Spec
+		// Leave the spec untouched in none mode
+		if r.prometheusReconcileStrategy != v1.ReconcileStrategyNone {
+			desired := templates.PrometheusTemplate.DeepCopy()
+			r.prometheus.ObjectMeta.Labels = map[string]string{monLabelKey: monLabelValue}
+			r.prometheus.Spec = desired.Spec
+		}
 
 		return nil
 	})
@@ -581,8 +1922,22 @@ func (r *ManagedOCSReconciler) reconcileDMSPrometheusRule() error {
 			return err
 		}
 
-		desired := templates.DMSPrometheusRuleTemplate.DeepCopy()
-		r.dmsRule.Spec = desired.Spec
+		// The DMS rule is evaluated by our prometheus and follows its reconcile strategy
+		if r.prometheusReconcileStrategy != v1.ReconcileStrategyNone {
+			desired := templates.DMSPrometheusRuleTemplate.DeepCopy()
+			if r.uninstallRequested {
+				// Mutes the storage alerts through the uninstall inhibition rule
+				desired.Spec.Groups = append(desired.Spec.Groups, promv1.RuleGroup{
+					Name: "uninstall-alert",
+					Rules: []promv1.Rule{{
+						Alert:  uninstallAlertName,
+						Expr:   intstr.FromString("vector(1)"),
+						Labels: map[string]string{"severity": severityInfo},
+					}},
+				})
+			}
+			r.dmsRule.Spec = desired.Spec
+		}
 
 		return nil
 	})
@@ -600,9 +1955,12 @@ func (r *ManagedOCSReconciler) reconcileAlertmanager() error {
 			return err
 		}
 
-		desired := templates.AlertmanagerTemplate.DeepCopy()
-		r.alertmanager.ObjectMeta.Labels = map[string]string{monLabelKey: monLabelValue}
-		r.alertmanager.Spec = desired.Spec
+		// Leave the spec untouched in none mode
+		if r.alertmanagerReconcileStrategy != v1.ReconcileStrategyNone {
+			desired := templates.AlertmanagerTemplate.DeepCopy()
+			r.alertmanager.ObjectMeta.Labels = map[string]string{monLabelKey: monLabelValue}
+			r.alertmanager.Spec = desired.Spec
+		}
 
 		return nil
 	})
@@ -620,24 +1978,42 @@ func (r *ManagedOCSReconciler) reconcileAlertmanagerConfigSecret() error {
 			return err
 		}
 
+		// Leave the config untouched in none mode
+		if r.alertmanagerConfigReconcileStrategy == v1.ReconcileStrategyNone {
+			return nil
+		}
+
 		if err := r.get(r.pagerdutySecret); err != nil {
-			return fmt.Errorf("Unable to get pagerduty secret: %v", err)
+			return newConfigurationError(reasonPagerdutySecretError, "Unable to get pagerduty secret: %v", err)
 		}
 		pagerdutySecretData := r.pagerdutySecret.Data
 		pagerdutyServiceKey := string(pagerdutySecretData["PAGERDUTY_KEY"])
 		if pagerdutyServiceKey == "" {
-			return fmt.Errorf("Pagerduty secret does not contain a PAGERDUTY_KEY entry")
+			return newConfigurationError(reasonPagerdutySecretError, "Pagerduty secret does not contain a PAGERDUTY_KEY entry")
+		}
+		// The low-urgency service key is optional, low-urgency alerts page without it
+		pagerdutyLowUrgencyServiceKey := string(pagerdutySecretData["PAGERDUTY_LOW_URGENCY_KEY"])
+		if pagerdutyLowUrgencyServiceKey == "" {
+			r.Log.Info("Pagerduty secret does not contain a PAGERDUTY_LOW_URGENCY_KEY entry, " +
+				"low-urgency alerts are sent to the PAGERDUTY_KEY service")
 		}
 
 		if err := r.get(r.deadMansSnitchSecret); err != nil {
-			return fmt.Errorf("Unable to get DeadMan's Snitch secret: %v", err)
+			return newConfigurationError(reasonDeadMansSnitchSecretError, "Unable to get DeadMan's Snitch secret: %v", err)
 		}
 		dmsURL := string(r.deadMansSnitchSecret.Data["SNITCH_URL"])
 		if dmsURL == "" {
-			return fmt.Errorf("DeadMan's Snitch secret does not contain a SNITCH_URL entry")
+			return newConfigurationError(reasonDeadMansSnitchSecretError, "DeadMan's Snitch secret does not contain a SNITCH_URL entry")
 		}
 
-		alertmanagerConfig := r.generateAlertmanagerConfig(pagerdutyServiceKey, dmsURL)
+		receivers, err := r.getAlertmanagerReceivers()
+		if err != nil {
+			return err
+		}
+		alertmanagerConfig, err := r.generateAlertmanagerConfig(pagerdutyServiceKey, pagerdutyLowUrgencyServiceKey, dmsURL, receivers)
+		if err != nil {
+			return err
+		}
 		config, err := yaml.Marshal(alertmanagerConfig)
 		if err != nil {
 			return fmt.Errorf("Unable to encode alertmanager conifg: %v", err)
@@ -651,66 +2027,6 @@ func (r *ManagedOCSReconciler) reconcileAlertmanagerConfigSecret() error {
 	return err
 }
 
-// Prometheus-operator v0.37.0 takes its alert manager configuration from a secret containing a yaml file.
-// This function produces that yaml file.
-// To sanitize the input the yaml is first represented as types in golang.
-func (r *ManagedOCSReconciler) generateAlertmanagerConfig(pagerdutyServiceKey string, dmsURL string) interface{} {
-	type PagerDutyConfig struct {
-		ServiceKey string `yaml:"service_key"`
-	}
-
-	type WebhookConfig struct {
-		Url string `yaml:"url"`
-	}
-
-	alertmanagerConfig := struct {
-		Route struct {
-			GroupWait      string `yaml:"group_wait,omitempty"`
-			GroupInterval  string `yaml:"group_interval,omitempty"`
-			RepeatInterval string `yaml:"repeat_interval,omitempty"`
-			Receiver       string `yaml:"receiver,omitempty"`
-			Routes         [2]struct {
-				GroupWait      string            `yaml:"group_wait,omitempty"`
-				GroupInterval  string            `yaml:"group_interval,omitempty"`
-				RepeatInterval string            `yaml:"repeat_interval,omitempty"`
-				Receiver       string            `yaml:"receiver,omitempty"`
-				Match          map[string]string `yaml:"match,omitempty"`
-			} `yaml:"routes"`
-		} `yaml:"route"`
-		Receivers [2]struct {
-			Name             string            `yaml:"name"`
-			PagerdutyConfigs []PagerDutyConfig `yaml:"pagerduty_configs,omitempty"`
-			WebhookConfigs   []WebhookConfig   `yaml:"webhook_configs,omitempty"`
-		} `yaml:"receivers"`
-	}{}
-
-	alertmanagerConfig.Route.Receiver = "pagerduty"
-
-	alertmanagerConfig.Route.Routes[0].GroupWait = "30s"
-	alertmanagerConfig.Route.Routes[0].GroupInterval = "5m"
-	alertmanagerConfig.Route.Routes[0].RepeatInterval = "4h"
-	alertmanagerConfig.Route.Routes[0].Receiver = "pagerduty"
-	alertmanagerConfig.Route.Routes[0].Match = make(map[string]string)
-	alertmanagerConfig.Route.Routes[0].Match["severity"] = "critical"
-
-	alertmanagerConfig.Route.Routes[1].GroupWait = "5m"
-	alertmanagerConfig.Route.Routes[1].GroupInterval = "5m"
-	alertmanagerConfig.Route.Routes[1].RepeatInterval = "5m"
-	alertmanagerConfig.Route.Routes[1].Receiver = "DeadMansSnitch"
-	alertmanagerConfig.Route.Routes[1].Match = make(map[string]string)
-	alertmanagerConfig.Route.Routes[1].Match["alertname"] = "DeadMansSnitch"
-
-	alertmanagerConfig.Receivers[0].Name = "pagerduty"
-	alertmanagerConfig.Receivers[0].PagerdutyConfigs = []PagerDutyConfig{{}}
-	alertmanagerConfig.Receivers[0].PagerdutyConfigs[0].ServiceKey = pagerdutyServiceKey
-
-	alertmanagerConfig.Receivers[1].Name = "DeadMansSnitch"
-	alertmanagerConfig.Receivers[1].WebhookConfigs = []WebhookConfig{{}}
-	alertmanagerConfig.Receivers[1].WebhookConfigs[0].Url = dmsURL
-
-	return &alertmanagerConfig
-}
-
 // reconcileMonitoringResources labels all monitoring resources (ServiceMonitors, PodMonitors, and PrometheusRules)
 // found in the target namespace with a label that matches the label selector the defined on the Prometheus resource
 // we are reconciling in reconcilePrometheus. Doing so instructs the Prometheus instance to notice and react to these labeled
@@ -718,16 +2034,19 @@ func (r *ManagedOCSReconciler) generateAlertmanagerConfig(pagerdutyServiceKey st
 func (r *ManagedOCSReconciler) reconcileMonitoringResources() error {
 	r.Log.Info("reconciling monitoring resources")
 
+	if r.monitoringLabelsReconcileStrategy == v1.ReconcileStrategyNone {
+		r.Log.Info("monitoring labels reconcile strategy is none, skipping")
+		return nil
+	}
+
+	resources := []monitoringResource{}
+
 	podMonitorList := promv1.PodMonitorList{}
 	if err := r.list(&podMonitorList); err != nil {
 		return fmt.Errorf("Could not list pod monitors: %v", err)
 	}
 	for i := range podMonitorList.Items {
-		obj := podMonitorList.Items[i]
-		utils.AddLabel(obj, monLabelKey, monLabelValue)
-		if err := r.update(obj); err != nil {
-			return err
-		}
+		resources = append(resources, monitoringResource{v1.MonitoringResourceKindPodMonitor, podMonitorList.Items[i]})
 	}
 
 	serviceMonitorList := promv1.ServiceMonitorList{}
@@ -735,11 +2054,7 @@ func (r *ManagedOCSReconciler) reconcileMonitoringResources() error {
 		return fmt.Errorf("Could not list service monitors: %v", err)
 	}
 	for i := range serviceMonitorList.Items {
-		obj := serviceMonitorList.Items[i]
-		utils.AddLabel(obj, monLabelKey, monLabelValue)
-		if err := r.update(obj); err != nil {
-			return err
-		}
+		resources = append(resources, monitoringResource{v1.MonitoringResourceKindServiceMonitor, serviceMonitorList.Items[i]})
 	}
 
 	promRuleList := promv1.PrometheusRuleList{}
@@ -747,30 +2062,77 @@ func (r *ManagedOCSReconciler) reconcileMonitoringResources() error {
 		return fmt.Errorf("Could not list prometheus rules: %v", err)
 	}
 	for i := range promRuleList.Items {
-		obj := promRuleList.Items[i]
-		utils.AddLabel(obj, monLabelKey, monLabelValue)
-		if err := r.update(obj); err != nil {
+		resources = append(resources, monitoringResource{v1.MonitoringResourceKindPrometheusRule, promRuleList.Items[i]})
+	}
+
+	adopted := []v1.MonitoringResourceReference{}
+	for _, resource := range resources {
+		adopt, err := isMonitoringResourceAdopted(&r.managedOCS.Spec.MonitoringResources, resource.kind, resource.obj)
+		if err != nil {
+			return newConfigurationError(reasonInvalidMonitoringFilter,
+				"Invalid monitoring resource filter: %v", err)
+		}
+
+		labels := resource.obj.GetLabels()
+		if adopt {
+			adopted = append(adopted, v1.MonitoringResourceReference{Kind: resource.kind, Name: resource.obj.GetName()})
+			if labels[monLabelKey] == monLabelValue {
+				continue
+			}
+			utils.AddLabel(resource.obj, monLabelKey, monLabelValue)
+		} else {
+			if labels[monLabelKey] != monLabelValue {
+				continue
+			}
+			r.Log.Info("Removing the monitoring label from an excluded resource", "kind", resource.kind, "name", resource.obj.GetName())
+			delete(labels, monLabelKey)
+		}
+		if err := r.update(resource.obj); err != nil {
 			return err
 		}
 	}
 
+	sort.Slice(adopted, func(i, j int) bool {
+		if adopted[i].Kind != adopted[j].Kind {
+			return adopted[i].Kind < adopted[j].Kind
+		}
+		return adopted[i].Name < adopted[j].Name
+	})
+	if len(adopted) == 0 {
+		adopted = nil
+	}
+	r.managedOCS.Status.AdoptedMonitoringResources = adopted
+
 	return nil
 }
 
 func (r *ManagedOCSReconciler) checkUninstallCondition() bool {
-	configmap := &corev1.ConfigMap{}
-	configmap.Name = r.AddonConfigMapName
-	configmap.Namespace = r.namespace
-
-	err := r.get(configmap)
+	requested, err := isAddonUninstallRequested(r.ctx, r.Client, r.namespace,
+		r.AddonConfigMapName, r.AddonConfigMapDeleteLabelKey)
 	if err != nil {
-		if !errors.IsNotFound(err) {
-			r.Log.Error(err, "Unable to get addon delete configmap")
+		r.Log.Error(err, "Unable to get addon delete configmap")
+	}
+	return requested
+}
+
+// isAddonUninstallRequested checks whether the add-on configmap carries the add-on delete label
+func isAddonUninstallRequested(ctx context.Context, c client.Client, namespace string, configMapName string, deleteLabelKey string) (bool, error) {
+	configmap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: namespace, Name: configMapName}
+	if err := c.Get(ctx, key, configmap); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
 		}
-		return false
+		return false, err
 	}
-	_, ok := configmap.Labels[r.AddonConfigMapDeleteLabelKey]
-	return ok
+	_, ok := configmap.Labels[deleteLabelKey]
+	return ok, nil
+}
+
+// isForceDeleteRequested checks whether ManagedOCS was annotated to allow deletion outside
+// of the add-on uninstall flow
+func isForceDeleteRequested(managedOCS *v1.ManagedOCS) bool {
+	return strings.EqualFold(managedOCS.GetAnnotations()[forceDeleteAnnotationKey], "true")
 }
 
 func (r *ManagedOCSReconciler) areComponentsReadyForUninstall() bool {
@@ -786,9 +2148,31 @@ func (r *ManagedOCSReconciler) findOCSVolumeClaims() (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("unable to list pvcs: %v", err)
 	}
+	storageClassNames, err := r.listOCSStorageClassNames()
+	if err != nil {
+		return false, err
+	}
 	for i := range pvcList.Items {
-		scName := *pvcList.Items[i].Spec.StorageClassName
-		if scName == storageClassCephFSName || scName == storageClassRbdName {
+		if persistentVolumeClaimUsesStorageClass(&pvcList.Items[i], storageClassNames) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findOCSObjectBucketClaims checks for object bucket claims backed by the multi-cloud gateway
+func (r *ManagedOCSReconciler) findOCSObjectBucketClaims() (bool, error) {
+	obcList := &obv1.ObjectBucketClaimList{}
+	if err := r.UnrestrictedClient.List(r.ctx, obcList); err != nil {
+		// The ObjectBucketClaim CRD is installed along with NooBaa
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to list obcs: %v", err)
+	}
+	noobaaStorageClassName := fmt.Sprintf("%s.noobaa.io", r.namespace)
+	for i := range obcList.Items {
+		if obcList.Items[i].Spec.StorageClassName == noobaaStorageClassName {
 			return true, nil
 		}
 	}
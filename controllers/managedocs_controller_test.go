@@ -3,19 +3,28 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	snapv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	nbv1 "github.com/noobaa/noobaa-operator/v2/pkg/apis/noobaa/v1alpha1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	ocsv1 "github.com/openshift/ocs-operator/pkg/apis/ocs/v1"
 	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	"github.com/openshift/ocs-osd-deployer/templates"
 	utils "github.com/openshift/ocs-osd-deployer/testutils"
 	ctrlutils "github.com/openshift/ocs-osd-deployer/utils"
 	opv1a1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"gopkg.in/yaml.v2"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -53,6 +62,12 @@ var _ = Describe("ManagedOCS controller", func() {
 			Namespace: testPrimaryNamespace,
 		},
 	}
+	cephPromRuleTemplate := promv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cephRuleName,
+			Namespace: testPrimaryNamespace,
+		},
+	}
 	promStsTemplate := appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("prometheus-%s", prometheusName),
@@ -185,6 +200,16 @@ var _ = Describe("ManagedOCS controller", func() {
 			},
 		},
 	}
+	obcTemplate := obv1.ObjectBucketClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-obc",
+			Namespace: testPrimaryNamespace,
+		},
+		Spec: obv1.ObjectBucketClaimSpec{
+			StorageClassName:   fmt.Sprintf("%s.noobaa.io", testPrimaryNamespace),
+			GenerateBucketName: "test-obc",
+		},
+	}
 	subscriptionTemplate := opv1a1.Subscription{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      testSubscriptionName,
@@ -198,6 +223,16 @@ var _ = Describe("ManagedOCS controller", func() {
 		},
 	}
 
+	getTrueConditionReason := func(conditionType string) string {
+		managedOCS := managedOCSTemplate.DeepCopy()
+		Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+		cond := meta.FindStatusCondition(managedOCS.Status.Conditions, conditionType)
+		if cond == nil || cond.Status != metav1.ConditionTrue {
+			return ""
+		}
+		return cond.Reason
+	}
+
 	setupUninstallConditions := func(
 		shouldAddonConfigMapExist bool,
 		addonConfigMapDeleteLabel string,
@@ -319,6 +354,11 @@ var _ = Describe("ManagedOCS controller", func() {
 				}
 				utils.EnsureNoResources(k8sClient, ctx, resList, timeout, interval)
 			})
+			It("should report the missing secret as an invalid configuration", func() {
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionConfigurationInvalid)
+				}, timeout, interval).Should(Equal(reasonAddonParamsSecretError))
+			})
 		})
 		When("there is no size field in the add-on parameters secret", func() {
 			It("should not create a reconciled resources", func() {
@@ -353,6 +393,43 @@ var _ = Describe("ManagedOCS controller", func() {
 				}
 				utils.EnsureNoResources(k8sClient, ctx, resList, timeout, interval)
 
+				By("reporting the invalid size through the ManagedOCS conditions")
+				Expect(getTrueConditionReason(v1.ConditionConfigurationInvalid)).Should(Equal(reasonInvalidSize))
+				Expect(getTrueConditionReason(v1.ConditionDegraded)).Should(Equal(reasonReconcileFailed))
+
+				By("recording the failing phase in the ManagedOCS status")
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				Expect(managedOCS.Status.ObservedGeneration).Should(Equal(managedOCS.Generation))
+				Expect(managedOCS.Status.LastReconcileTime).ShouldNot(BeNil())
+				Expect(managedOCS.Status.LastError).Should(HavePrefix("reconcileStorageCluster: "))
+
+				// Remove the secret for future cases
+				Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+			})
+		})
+		When("the size in the add-on parameters secret is not a multiple of the device set volume size", func() {
+			It("should not create reconciled resources", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				secret.Data["size"] = []byte("20T")
+				Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+
+				resList := []runtime.Object{
+					scTemplate.DeepCopy(),
+					promTemplate.DeepCopy(),
+					amTemplate.DeepCopy(),
+				}
+				utils.EnsureNoResources(k8sClient, ctx, resList, timeout, interval)
+
+				By("reporting the invalid size through the ManagedOCS conditions")
+				Expect(getTrueConditionReason(v1.ConditionConfigurationInvalid)).Should(Equal(reasonInvalidSize))
+
+				By("listing the closest accepted sizes in the condition message")
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				cond := meta.FindStatusCondition(managedOCS.Status.Conditions, v1.ConditionConfigurationInvalid)
+				Expect(cond.Message).Should(ContainSubstring("such as 18Ti or 19Ti"))
+
 				// Remove the secret for future cases
 				Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
 			})
@@ -372,6 +449,28 @@ var _ = Describe("ManagedOCS controller", func() {
 
 				By("Creating an alertmanager resource")
 				utils.WaitForResource(k8sClient, ctx, amTemplate.DeepCopy(), timeout, interval)
+
+				By("Using the storage class of the platform")
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				Expect(*sc.Spec.MonPVCTemplate.Spec.StorageClassName).Should(Equal(testStorageClassName))
+				Expect(*sc.Spec.StorageDeviceSets[0].DataPVCTemplate.Spec.StorageClassName).Should(Equal(testStorageClassName))
+			})
+		})
+		When("a storage class that does not exist is requested for an existing storagecluster", func() {
+			It("should keep the storage class of the storagecluster", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				secret.Data["size"] = []byte("1")
+				secret.Data["storage-class"] = []byte("does-not-exist")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				// The requested storage class is only used for new storage clusters
+				Consistently(func() string {
+					return getTrueConditionReason(v1.ConditionConfigurationInvalid)
+				}, timeout, interval).ShouldNot(Equal(reasonStorageClassNotFound))
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				Expect(*sc.Spec.StorageDeviceSets[0].DataPVCTemplate.Spec.StorageClassName).Should(Equal(testStorageClassName))
 			})
 		})
 		When("size is increased in the add-on parameters secret", func() {
@@ -400,6 +499,23 @@ var _ = Describe("ManagedOCS controller", func() {
 				}, timeout, interval).Should(BeTrue())
 
 			})
+			It("should report the applied capacity in the ManagedOCS resource status", func() {
+				Eventually(func() bool {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					capacity := managedOCS.Status.Capacity
+					return capacity.RequestedSize == "4" && capacity.DeviceSetCount == 4 &&
+						capacity.Replica == 3 && !capacity.DownscaleRejected
+				}, timeout, interval).Should(BeTrue())
+
+				By("applying the resource profile matching the size")
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				Expect(managedOCS.Status.ResourceProfile).Should(Equal(templates.ResourceProfileMedium))
+
+				Expect(managedOCS.Status.Capacity.UsableCapacity.Cmp(resource.MustParse("4Ti"))).Should(BeZero())
+				Expect(managedOCS.Status.Capacity.RawCapacity.Cmp(resource.MustParse("12Ti"))).Should(BeZero())
+			})
 		})
 		When("size is decreased in the add-on parameters secret", func() {
 			It("should not decrease storagecluster's storage device set count", func() {
@@ -425,6 +541,431 @@ var _ = Describe("ManagedOCS controller", func() {
 					return ds != nil && ds.Count == 4
 				}, timeout, interval).Should(BeTrue())
 			})
+			It("should report the rejected downscale in the ManagedOCS resource status", func() {
+				Eventually(func() bool {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.Capacity.DownscaleRejected &&
+						managedOCS.Status.Capacity.RequestedSize == "1" &&
+						managedOCS.Status.Capacity.DeviceSetCount == 4
+				}, timeout, interval).Should(BeTrue())
+			})
+		})
+		When("size is given as a quantity in the add-on parameters secret", func() {
+			It("should convert it into a storage device set count", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				secret.Data["size"] = []byte("4096Gi")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() bool {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					capacity := managedOCS.Status.Capacity
+					return capacity.RequestedSize == "4096Gi" && capacity.DeviceSetCount == 4 && !capacity.DownscaleRejected
+				}, timeout, interval).Should(BeTrue())
+			})
+		})
+		When("size is increased with a maximum device set count step", func() {
+			osdPVCs := []*corev1.PersistentVolumeClaim{}
+
+			getScaleUpStatus := func() (int, v1.ScaleUpStatus) {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				return managedOCS.Status.Capacity.DeviceSetCount, managedOCS.Status.Capacity.ScaleUp
+			}
+
+			It("should wait for the OSD pvcs to be bound before scaling up", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.Components.StorageCluster.MaxDeviceSetCountStep = 1
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				sc.Status.Phase = "Ready"
+				Expect(k8sClient.Status().Update(ctx, sc)).Should(Succeed())
+
+				secret := addonParamsSecretTemplate.DeepCopy()
+				secret.Data["size"] = []byte("6")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() bool {
+					count, scaleUp := getScaleUpStatus()
+					return count == 4 && scaleUp.InProgress && scaleUp.TargetDeviceSetCount == 6
+				}, timeout, interval).Should(BeTrue())
+				Consistently(func() int {
+					count, _ := getScaleUpStatus()
+					return count
+				}, timeout, interval).Should(Equal(4))
+			})
+			It("should take a single step once the OSD pvcs are bound", func() {
+				storageClassName := "gp2"
+				for i := 0; i < 4*3; i++ {
+					pvc := &corev1.PersistentVolumeClaim{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      fmt.Sprintf("osd-pvc-%d", i),
+							Namespace: testPrimaryNamespace,
+							Labels:    map[string]string{rookDeviceSetLabelKey: fmt.Sprintf("%s-%d", deviceSetName, i%3)},
+						},
+						Spec: corev1.PersistentVolumeClaimSpec{
+							StorageClassName: &storageClassName,
+							AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Ti")},
+							},
+						},
+					}
+					Expect(k8sClient.Create(ctx, pvc)).Should(Succeed())
+					pvc.Status.Phase = corev1.ClaimBound
+					Expect(k8sClient.Status().Update(ctx, pvc)).Should(Succeed())
+					osdPVCs = append(osdPVCs, pvc)
+				}
+
+				Eventually(func() int {
+					count, _ := getScaleUpStatus()
+					return count
+				}, timeout, interval).Should(Equal(5))
+				Consistently(func() bool {
+					count, scaleUp := getScaleUpStatus()
+					return count == 5 && scaleUp.InProgress
+				}, timeout, interval).Should(BeTrue())
+			})
+			It("should scale up to the requested size once the step limit is removed", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.Components.StorageCluster.MaxDeviceSetCountStep = 0
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				Eventually(func() bool {
+					count, scaleUp := getScaleUpStatus()
+					return count == 6 && !scaleUp.InProgress
+				}, timeout, interval).Should(BeTrue())
+
+				// Remove the pvcs for future cases
+				for _, pvc := range osdPVCs {
+					Expect(k8sClient.Delete(ctx, pvc)).Should(Succeed())
+				}
+			})
+		})
+		When("size is increased beyond the resources of the storage nodes", func() {
+			It("should hold the scale-up and report the missing resources", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				secret.Data["size"] = []byte("8")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionInsufficientCapacity)
+				}, timeout, interval).Should(Equal(reasonNodeResourcesInsufficient))
+
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				condition := meta.FindStatusCondition(managedOCS.Status.Conditions, v1.ConditionInsufficientCapacity)
+				Expect(condition.Message).Should(ContainSubstring("memory: 17Gi"))
+				Expect(managedOCS.Status.Capacity.DeviceSetCount).Should(Equal(6))
+				Expect(managedOCS.Status.Capacity.ScaleUp.InProgress).Should(BeTrue())
+			})
+			It("should scale up once the storage nodes have enough resources", func() {
+				node := &corev1.Node{}
+				node.Name = testWorkerNodeName
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(node), node)).Should(Succeed())
+				node.Status.Allocatable[corev1.ResourceMemory] = resource.MustParse("256Gi")
+				Expect(k8sClient.Status().Update(ctx, node)).Should(Succeed())
+
+				// Node changes are not watched, touch the add-on parameters secret to trigger a reconcile
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				secret.Annotations = map[string]string{"test": "node-resized"}
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() int {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.Capacity.DeviceSetCount
+				}, timeout, interval).Should(Equal(8))
+				Expect(getTrueConditionReason(v1.ConditionInsufficientCapacity)).Should(BeEmpty())
+			})
+		})
+		When("the storage nodes span less than three zones", func() {
+			It("should report the missing zones in the ManagedOCS resource status", func() {
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionInsufficientZones)
+				}, timeout, interval).Should(Equal(reasonZonesInsufficient))
+			})
+		})
+		When("the storage nodes span three zones", func() {
+			It("should spread the OSDs across the zones", func() {
+				zones := []string{"zone-a", "zone-b", "zone-c"}
+				node := &corev1.Node{}
+				node.Name = testWorkerNodeName
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(node), node)).Should(Succeed())
+				originalZone, hadZone := node.Labels[zoneLabelKey]
+				node.Labels[zoneLabelKey] = zones[0]
+				Expect(k8sClient.Update(ctx, node)).Should(Succeed())
+				for i, zone := range zones[1:] {
+					node := &corev1.Node{}
+					node.Name = fmt.Sprintf("%s-%d", testWorkerNodeName, i)
+					node.Labels = map[string]string{
+						"node-role.kubernetes.io/worker": "",
+						zoneLabelKey:                     zone,
+					}
+					Expect(k8sClient.Create(ctx, node)).Should(Succeed())
+				}
+
+				// Restore the single zone worker for future cases
+				defer func() {
+					for i := range zones[1:] {
+						node := &corev1.Node{}
+						node.Name = fmt.Sprintf("%s-%d", testWorkerNodeName, i)
+						Expect(k8sClient.Delete(ctx, node)).Should(Succeed())
+					}
+					node := &corev1.Node{}
+					node.Name = testWorkerNodeName
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(node), node)).Should(Succeed())
+					if hadZone {
+						node.Labels[zoneLabelKey] = originalZone
+					} else {
+						delete(node.Labels, zoneLabelKey)
+					}
+					Expect(k8sClient.Update(ctx, node)).Should(Succeed())
+				}()
+
+				// Node changes are not watched, touch the add-on parameters secret to trigger a reconcile
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				secret.Annotations = map[string]string{"test": "zones-added"}
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() []string {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.Zones
+				}, timeout, interval).Should(Equal(zones))
+				Expect(getTrueConditionReason(v1.ConditionInsufficientZones)).Should(BeEmpty())
+
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				placement := sc.Spec.StorageDeviceSets[0].Placement
+				Expect(placement.NodeAffinity).ShouldNot(BeNil())
+				Expect(placement.TopologySpreadConstraints).Should(HaveLen(1))
+				Expect(placement.TopologySpreadConstraints[0].TopologyKey).Should(Equal(zoneLabelKey))
+				Expect(placement.TopologySpreadConstraints[0].WhenUnsatisfiable).Should(Equal(corev1.DoNotSchedule))
+			})
+		})
+		When("a node pool is set in the add-on parameters secret", func() {
+			setNodePoolLabel := func(nodeName string, value string) {
+				node := &corev1.Node{}
+				node.Name = nodeName
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(node), node)).Should(Succeed())
+				if value == "" {
+					delete(node.Labels, "test-node-pool")
+				} else {
+					node.Labels["test-node-pool"] = value
+				}
+				Expect(k8sClient.Update(ctx, node)).Should(Succeed())
+			}
+			getStorageNodeCount := func() int {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				return managedOCS.Status.StorageNodeCount
+			}
+
+			// A worker outside of the pool, removed once the node pool is removed
+			outsideWorkerName := fmt.Sprintf("%s-outside-pool", testWorkerNodeName)
+
+			It("should restrict the storagecluster to the nodes of the pool", func() {
+				node := &corev1.Node{}
+				node.Name = outsideWorkerName
+				node.Labels = map[string]string{"node-role.kubernetes.io/worker": ""}
+				Expect(k8sClient.Create(ctx, node)).Should(Succeed())
+				setNodePoolLabel(testWorkerNodeName, "storage")
+
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				secret.Data[nodePoolLabelKey] = []byte("test-node-pool=storage")
+				secret.Data[nodePoolTaintKey] = []byte("test-node-pool=storage:NoSchedule")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(getStorageNodeCount, timeout, interval).Should(Equal(1))
+
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				Expect(sc.Spec.LabelSelector).ShouldNot(BeNil())
+				Expect(sc.Spec.LabelSelector.MatchExpressions).Should(ContainElements(
+					metav1.LabelSelectorRequirement{
+						Key:      "node-role.kubernetes.io/infra",
+						Operator: metav1.LabelSelectorOpDoesNotExist,
+					},
+					metav1.LabelSelectorRequirement{
+						Key:      "test-node-pool",
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   []string{"storage"},
+					},
+				))
+				toleration := corev1.Toleration{
+					Key:      "test-node-pool",
+					Operator: corev1.TolerationOpEqual,
+					Value:    "storage",
+					Effect:   corev1.TaintEffectNoSchedule,
+				}
+				for _, key := range cephDaemonPlacementKeys {
+					Expect(sc.Spec.Placement[key].Tolerations).Should(ContainElement(toleration))
+				}
+				Expect(sc.Spec.StorageDeviceSets[0].Placement.Tolerations).Should(ContainElement(toleration))
+			})
+			It("should report all the worker nodes once the node pool is removed", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				delete(secret.Data, nodePoolLabelKey)
+				delete(secret.Data, nodePoolTaintKey)
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+				setNodePoolLabel(testWorkerNodeName, "")
+
+				Eventually(getStorageNodeCount, timeout, interval).Should(Equal(2))
+
+				node := &corev1.Node{}
+				node.Name = outsideWorkerName
+				Expect(k8sClient.Delete(ctx, node)).Should(Succeed())
+			})
+		})
+		When("encryption is changed in the add-on parameters secret", func() {
+			setEncryptionParam := func(value string) {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				if value == "" {
+					delete(secret.Data, encryptionKey)
+				} else {
+					secret.Data[encryptionKey] = []byte(value)
+				}
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+			}
+			setStorageClusterEncryption := func(enable bool) {
+				// Retry on conflicts with the deployer updating the storagecluster
+				Eventually(func() error {
+					sc := scTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+					sc.Spec.Encryption.Enable = enable
+					return k8sClient.Update(ctx, sc)
+				}, timeout, interval).Should(Succeed())
+			}
+			isStorageClusterEncrypted := func() bool {
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				return sc.Spec.Encryption.Enable
+			}
+
+			It("should refuse to enable encryption on an existing storagecluster", func() {
+				setEncryptionParam("true")
+
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionEncryptionChangeRejected)
+				}, timeout, interval).Should(Equal(reasonEncryptionEnableRejected))
+				Expect(isStorageClusterEncrypted()).Should(BeFalse())
+			})
+			It("should refuse to disable encryption on an existing storagecluster", func() {
+				// Simulate a storagecluster that was created with encryption enabled
+				setStorageClusterEncryption(true)
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionEncryptionChangeRejected)
+				}, timeout, interval).Should(BeEmpty())
+
+				setEncryptionParam("false")
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionEncryptionChangeRejected)
+				}, timeout, interval).Should(Equal(reasonEncryptionDisableRejected))
+				Consistently(isStorageClusterEncrypted, timeout, interval).Should(BeTrue())
+
+				// Restore the unencrypted storagecluster for future cases
+				setEncryptionParam("")
+				setStorageClusterEncryption(false)
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionEncryptionChangeRejected)
+				}, timeout, interval).Should(BeEmpty())
+			})
+		})
+		When("the resource profile thresholds are changed", func() {
+			It("should apply the resource profile matching the new thresholds", func() {
+				configMap := &corev1.ConfigMap{}
+				configMap.Name = resourceProfilesConfigMapName
+				configMap.Namespace = testPrimaryNamespace
+				configMap.Data = map[string]string{templates.ResourceProfileLarge: "8Ti"}
+				Expect(k8sClient.Create(ctx, configMap)).Should(Succeed())
+
+				Eventually(func() string {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.ResourceProfile
+				}, timeout, interval).Should(Equal(templates.ResourceProfileLarge))
+
+				sc := scTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+				large := templates.ResourceProfiles[templates.ResourceProfileLarge]
+				Expect(equality.Semantic.DeepEqual(sc.Spec.Resources["mds"], large.Mds)).Should(BeTrue())
+				Expect(equality.Semantic.DeepEqual(sc.Spec.StorageDeviceSets[0].Resources, large.Osd)).Should(BeTrue())
+
+				// Remove the configmap for future cases
+				Expect(k8sClient.Delete(ctx, configMap)).Should(Succeed())
+				Eventually(func() string {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.ResourceProfile
+				}, timeout, interval).Should(Equal(templates.ResourceProfileMedium))
+			})
+		})
+		When("the multi-cloud gateway is enabled in the add-on parameters secret", func() {
+			It("should switch the storagecluster multi-cloud gateway to managed", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				secret.Data[mcgEnabledKey] = []byte("true")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Eventually(func() string {
+					sc := scTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+					if sc.Spec.MultiCloudGateway == nil {
+						return ""
+					}
+					return sc.Spec.MultiCloudGateway.ReconcileStrategy
+				}, timeout, interval).Should(Equal(mcgReconcileStrategyManage))
+			})
+			It("should report the NooBaa state in the ManagedOCS resource status", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey(managedOCS)
+				Eventually(func() v1.ComponentState {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					return managedOCS.Status.Components.NooBaa.State
+				}, timeout, interval).Should(Equal(v1.ComponentNotFound))
+
+				// Simulate ocs-operator deploying NooBaa
+				noobaa := &nbv1.NooBaa{}
+				noobaa.Name = noobaaName
+				noobaa.Namespace = testPrimaryNamespace
+				Expect(k8sClient.Create(ctx, noobaa)).Should(Succeed())
+				noobaa.Status.Phase = nbv1.SystemPhaseReady
+				Expect(k8sClient.Status().Update(ctx, noobaa)).Should(Succeed())
+
+				Eventually(func() v1.ComponentState {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					return managedOCS.Status.Components.NooBaa.State
+				}, timeout, interval).Should(Equal(v1.ComponentReady))
+			})
+		})
+		When("the multi-cloud gateway is disabled in the add-on parameters secret", func() {
+			It("should keep the storagecluster multi-cloud gateway managed", func() {
+				secret := addonParamsSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				secret.Data[mcgEnabledKey] = []byte("false")
+				Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+				Consistently(func() string {
+					sc := scTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)).Should(Succeed())
+					return sc.Spec.MultiCloudGateway.ReconcileStrategy
+				}, timeout, interval).Should(Equal(mcgReconcileStrategyManage))
+			})
 		})
 		When("the storagecluster is not ready", func() {
 			BeforeEach(func() {
@@ -550,6 +1091,11 @@ var _ = Describe("ManagedOCS controller", func() {
 					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
 					return managedOCS.Status.Components.Alertmanager.State
 				}, timeout, interval).Should(Equal(v1.ComponentReady))
+
+				By("by setting the Available condition")
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionAvailable)
+				}, timeout, interval).Should(Equal(reasonComponentsReady))
 			})
 		})
 		When("the storagecluster resource is deleted", func() {
@@ -565,9 +1111,11 @@ var _ = Describe("ManagedOCS controller", func() {
 			It("should revert the changes and bring the resource back to its managed state", func() {
 				// Set managed OCS to reconcile strategy to strict
 				managedOCS := managedOCSTemplate.DeepCopy()
-				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
-				managedOCS.Spec.ReconcileStrategy = ""
-				Expect(k8sClient.Update(ctx, managedOCS)).Should(Succeed())
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.ReconcileStrategy = ""
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
 
 				// Get an updated storagecluster
 				sc := scTemplate.DeepCopy()
@@ -591,9 +1139,11 @@ var _ = Describe("ManagedOCS controller", func() {
 			It("should revert the changes and bring the resource back to its managed state", func() {
 				// Set managed OCS to reconcile strategy to strict
 				managedOCS := managedOCSTemplate.DeepCopy()
-				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
-				managedOCS.Spec.ReconcileStrategy = v1.ReconcileStrategyStrict
-				Expect(k8sClient.Update(ctx, managedOCS)).Should(Succeed())
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.ReconcileStrategy = v1.ReconcileStrategyStrict
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
 
 				// Get an updated storagecluster
 				sc := scTemplate.DeepCopy()
@@ -617,9 +1167,11 @@ var _ = Describe("ManagedOCS controller", func() {
 			It("should not revert any changes back to the managed state", func() {
 				// Set managed OCS to reconcile strategy to none
 				managedOCS := managedOCSTemplate.DeepCopy()
-				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
-				managedOCS.Spec.ReconcileStrategy = v1.ReconcileStrategyNone
-				Expect(k8sClient.Update(ctx, managedOCS)).Should(Succeed())
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.ReconcileStrategy = v1.ReconcileStrategyNone
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
 
 				// Get an updated storagecluster
 				sc := scTemplate.DeepCopy()
@@ -638,6 +1190,37 @@ var _ = Describe("ManagedOCS controller", func() {
 				}, timeout, interval).Should(Equal(&sc.Spec))
 			})
 		})
+		When("the storagecluster reconcile strategy is set to merge with overrides", func() {
+			It("should apply the overrides on top of the managed state", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				managedOCSKey := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.Spec.ReconcileStrategy = ""
+					managedOCS.Spec.Components.StorageCluster.ReconcileStrategy = v1.ReconcileStrategyMerge
+					managedOCS.Spec.Components.StorageCluster.Overrides = &v1.StorageClusterOverrides{
+						Patch: "spec:\n  resources:\n    mgr:\n      limits:\n        cpu: 2000m\n",
+					}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				scKey := utils.GetResourceKey(scTemplate.DeepCopy())
+				Eventually(func() bool {
+					sc := scTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, scKey, sc)).Should(Succeed())
+					limit := sc.Spec.Resources["mgr"].Limits["cpu"]
+					return limit.Cmp(resource.MustParse("2000m")) == 0 && len(sc.Spec.StorageDeviceSets) == 1
+				}, timeout, interval).Should(BeTrue())
+
+				// Restore the strict reconcile strategy for future cases, retrying on conflicts
+				// with the deployer updating the status
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.Spec.Components.StorageCluster = v1.StorageClusterComponentSpec{}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+			})
+		})
 		When("the prometheus resource is modified", func() {
 			It("should revert the changes and bring the resource back to its managed state", func() {
 				// Get an updated prometheus
@@ -667,6 +1250,92 @@ var _ = Describe("ManagedOCS controller", func() {
 				utils.WaitForResource(k8sClient, ctx, promTemplate.DeepCopy(), timeout, interval)
 			})
 		})
+		When("the prometheus resource is modified while its component reconcile strategy is set to none", func() {
+			It("should not revert any changes back to the managed state", func() {
+				// Set the prometheus component reconcile strategy to none
+				managedOCS := managedOCSTemplate.DeepCopy()
+				managedOCSKey := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.Spec.Components.Prometheus.ReconcileStrategy = v1.ReconcileStrategyNone
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				// Get an updated prometheus
+				prom := promTemplate.DeepCopy()
+				promKey := utils.GetResourceKey(prom)
+				Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+
+				// Update to empty spec
+				prom.Spec = promv1.PrometheusSpec{}
+				Expect(k8sClient.Update(ctx, prom)).Should(Succeed())
+
+				// Verify that the spec changes are not reverted
+				Consistently(func() *promv1.PrometheusSpec {
+					prom := promTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+					return &prom.Spec
+				}, timeout, interval).Should(Equal(&prom.Spec))
+
+				// Restore the strict reconcile strategy for future cases
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.Spec.Components.Prometheus.ReconcileStrategy = ""
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+				Eventually(func() *promv1.PrometheusSpec {
+					prom := promTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+					return &prom.Spec
+				}, timeout, interval).ShouldNot(Equal(&promv1.PrometheusSpec{}))
+			})
+		})
+		When("the managedocs resource is paused through an annotation", func() {
+			It("should not revert changes to managed resources until it is unpaused", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				managedOCSKey := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.SetAnnotations(map[string]string{pausedAnnotationKey: "true"})
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				By("reporting the pause in the ManagedOCS status")
+				Eventually(func() bool {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					return managedOCS.Status.Paused && managedOCS.Status.PausedSince != nil
+				}, timeout, interval).Should(BeTrue())
+
+				// Get an updated prometheus
+				prom := promTemplate.DeepCopy()
+				promKey := utils.GetResourceKey(prom)
+				Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+
+				// Update to empty spec
+				spec := prom.Spec.DeepCopy()
+				prom.Spec = promv1.PrometheusSpec{}
+				Expect(k8sClient.Update(ctx, prom)).Should(Succeed())
+
+				// Verify that the spec changes are not reverted while paused
+				Consistently(func() *promv1.PrometheusSpec {
+					prom := promTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+					return &prom.Spec
+				}, timeout, interval).Should(Equal(&prom.Spec))
+
+				By("reverting the changes once unpaused")
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, managedOCSKey, managedOCS)).Should(Succeed())
+					managedOCS.SetAnnotations(nil)
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+				Eventually(func() *promv1.PrometheusSpec {
+					prom := promTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, promKey, prom)).Should(Succeed())
+					return &prom.Spec
+				}, timeout, interval).Should(Equal(spec))
+			})
+		})
 		When("the alertmanager resource is modified", func() {
 			It("should revert the changes and bring the resource back to its managed state", func() {
 				// Get an updated alertmanager
@@ -707,6 +1376,11 @@ var _ = Describe("ManagedOCS controller", func() {
 				// Ensure, over a period of time, that the resources are not created
 				utils.EnsureNoResource(k8sClient, ctx, amConfigSecretTemplate.DeepCopy(), timeout, interval)
 			})
+			It("should report the missing secret as an invalid configuration", func() {
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionConfigurationInvalid)
+				}, timeout, interval).Should(Equal(reasonPagerdutySecretError))
+			})
 		})
 		When("there is no deadmanssnitch secret in the cluster", func() {
 			It("should not create alertmanager config secret", func() {
@@ -762,6 +1436,138 @@ var _ = Describe("ManagedOCS controller", func() {
 
 				utils.WaitForResource(k8sClient, ctx, amConfigSecretTemplate.DeepCopy(), timeout, interval)
 			})
+			It("should record a successful reconcile in the ManagedOCS status", func() {
+				key := utils.GetResourceKey(managedOCSTemplate)
+				Eventually(func() bool {
+					// Get a fresh copy on every poll, an empty last error is omitted from the resource
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					return managedOCS.Status.LastError == "" &&
+						managedOCS.Status.LastSuccessfulReconcileTime != nil
+				}, timeout, interval).Should(BeTrue())
+			})
+		})
+		When("an alert route to a receiver secret is added to the ManagedOCS spec", func() {
+			getAlertmanagerConfig := func() *alertmanagerConfig {
+				secret := amConfigSecretTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(secret), secret)).Should(Succeed())
+				config := &alertmanagerConfig{}
+				Expect(yaml.Unmarshal(secret.Data["alertmanager.yaml"], config)).Should(Succeed())
+				return config
+			}
+
+			It("should add the receiver and the route to the alertmanager config", func() {
+				receiverSecret := &corev1.Secret{}
+				receiverSecret.Name = "test-webhook-receiver"
+				receiverSecret.Namespace = testPrimaryNamespace
+				receiverSecret.Labels = map[string]string{alertmanagerReceiverLabelKey: receiverTypeWebhook}
+				receiverSecret.Data = map[string][]byte{"url": []byte("http://example.com/hook")}
+				Expect(k8sClient.Create(ctx, receiverSecret)).Should(Succeed())
+
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					managedOCS.Spec.Alerting.Routes = []v1.AlertRouteSpec{{
+						Receiver: receiverSecret.Name,
+						Match:    map[string]string{"namespace": "test"},
+					}}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				Eventually(func() bool {
+					config := getAlertmanagerConfig()
+					routes := config.Route.Routes
+					return len(routes) == 5 &&
+						routes[1].Receiver == receiverSecret.Name &&
+						routes[1].Match["namespace"] == "test"
+				}, timeout, interval).Should(BeTrue())
+				config := getAlertmanagerConfig()
+				Expect(config.Receivers).Should(ContainElement(alertmanagerReceiver{
+					Name:           receiverSecret.Name,
+					WebhookConfigs: []webhookConfig{{Url: "http://example.com/hook"}},
+				}))
+			})
+			It("should remove the receiver once the route and the secret are removed", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					managedOCS.Spec.Alerting.Routes = nil
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				receiverSecret := &corev1.Secret{}
+				receiverSecret.Name = "test-webhook-receiver"
+				receiverSecret.Namespace = testPrimaryNamespace
+				Expect(k8sClient.Delete(ctx, receiverSecret)).Should(Succeed())
+
+				Eventually(func() int {
+					return len(getAlertmanagerConfig().Receivers)
+				}, timeout, interval).Should(Equal(4))
+			})
+		})
+		When("an additional storage class is added to the ManagedOCS spec", func() {
+			It("should create the storage class and its snapshot class", func() {
+				retain := corev1.PersistentVolumeReclaimRetain
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					managedOCS.Spec.StorageClasses = []v1.StorageClassSpec{{
+						Name:          "ocs-storagecluster-ceph-rbd-retain",
+						Type:          v1.StorageClassTypeRBD,
+						ReclaimPolicy: &retain,
+						FSType:        "xfs",
+						SnapshotClass: &v1.VolumeSnapshotClassSpec{},
+					}}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				storageClass := &storagev1.StorageClass{}
+				storageClass.Name = "ocs-storagecluster-ceph-rbd-retain"
+				Eventually(func() error {
+					return k8sClient.Get(ctx, utils.GetResourceKey(storageClass), storageClass)
+				}, timeout, interval).Should(Succeed())
+				Expect(storageClass.Provisioner).Should(Equal(fmt.Sprintf("%s.rbd.csi.ceph.com", testPrimaryNamespace)))
+				Expect(storageClass.ReclaimPolicy).ShouldNot(BeNil())
+				Expect(*storageClass.ReclaimPolicy).Should(Equal(retain))
+				Expect(storageClass.Parameters["csi.storage.k8s.io/fstype"]).Should(Equal("xfs"))
+				Expect(storageClass.Labels[storageClassOwnerLabelKey]).Should(Equal(testPrimaryNamespace))
+
+				snapshotClass := &snapv1beta1.VolumeSnapshotClass{}
+				snapshotClass.Name = storageClass.Name
+				Eventually(func() error {
+					return k8sClient.Get(ctx, utils.GetResourceKey(snapshotClass), snapshotClass)
+				}, timeout, interval).Should(Succeed())
+				Expect(snapshotClass.Driver).Should(Equal(storageClass.Provisioner))
+				Expect(snapshotClass.DeletionPolicy).Should(Equal(snapv1beta1.VolumeSnapshotContentDelete))
+			})
+		})
+		When("an additional storage class is removed from the ManagedOCS spec", func() {
+			It("should delete the storage class and its snapshot class", func() {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey(managedOCS)
+				Eventually(func() error {
+					Expect(k8sClient.Get(ctx, key, managedOCS)).Should(Succeed())
+					managedOCS.Spec.StorageClasses = nil
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				storageClass := &storagev1.StorageClass{}
+				storageClass.Name = "ocs-storagecluster-ceph-rbd-retain"
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, utils.GetResourceKey(storageClass), storageClass)
+					return errors.IsNotFound(err)
+				}, timeout, interval).Should(BeTrue())
+
+				snapshotClass := &snapv1beta1.VolumeSnapshotClass{}
+				snapshotClass.Name = storageClass.Name
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, utils.GetResourceKey(snapshotClass), snapshotClass)
+					return errors.IsNotFound(err)
+				}, timeout, interval).Should(BeTrue())
+			})
 		})
 		When("the dms prometheus rule resource is deleted", func() {
 			It("should create a new dms prometheus rule in the namespace", func() {
@@ -775,6 +1581,37 @@ var _ = Describe("ManagedOCS controller", func() {
 				utils.WaitForResource(k8sClient, ctx, dmsPromRuleTemplate.DeepCopy(), timeout, interval)
 			})
 		})
+		When("the ceph alert thresholds are tuned", func() {
+			It("should apply the thresholds to the ceph prometheus rule", func() {
+				utils.WaitForResource(k8sClient, ctx, cephPromRuleTemplate.DeepCopy(), timeout, interval)
+
+				Eventually(func() error {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.Alerting.Ceph.NearFullPercent = 70
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				Eventually(func() bool {
+					rule := cephPromRuleTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(rule), rule)).Should(Succeed())
+					for _, alert := range rule.Spec.Groups[0].Rules {
+						if alert.Alert == cephClusterNearFullAlert {
+							return strings.HasSuffix(alert.Expr.StrVal, "> 70")
+						}
+					}
+					return false
+				}, timeout, interval).Should(BeTrue())
+
+				// Restore the default thresholds for future cases
+				Eventually(func() error {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.Alerting.Ceph = v1.CephAlertsSpec{}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+			})
+		})
 		When("there is a pod monitor without an ocs-dedicated label", func() {
 			It("should add the label to the pod monitor resource", func() {
 				pm := podMonitorTemplate.DeepCopy()
@@ -805,6 +1642,46 @@ var _ = Describe("ManagedOCS controller", func() {
 				}, timeout, interval).Should(BeTrue())
 			})
 		})
+		When("the prometheus rule is denied by the monitoring resource filters", func() {
+			It("should remove the label from the prometheus rule resource", func() {
+				Eventually(func() error {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.MonitoringResources.Deny = []v1.MonitoringResourceSelector{{
+						Kind:        v1.MonitoringResourceKindPrometheusRule,
+						NamePattern: "test-*",
+					}}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				// Get a fresh copy on every poll, decoding into the same object keeps removed labels
+				Eventually(func() bool {
+					return utils.ResourceHasLabel(k8sClient, ctx, promRuleTemplate.DeepCopy(), monLabelKey, monLabelValue)
+				}, timeout, interval).Should(BeFalse())
+			})
+			It("should only list the adopted resources in the ManagedOCS resource status", func() {
+				Eventually(func() []v1.MonitoringResourceReference {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					return managedOCS.Status.AdoptedMonitoringResources
+				}, timeout, interval).Should(And(
+					ContainElement(v1.MonitoringResourceReference{Kind: v1.MonitoringResourceKindPodMonitor, Name: podMonitorTemplate.Name}),
+					ContainElement(v1.MonitoringResourceReference{Kind: v1.MonitoringResourceKindPrometheusRule, Name: dmsRuleName}),
+					Not(ContainElement(v1.MonitoringResourceReference{Kind: v1.MonitoringResourceKindPrometheusRule, Name: promRuleTemplate.Name})),
+				))
+
+				// Remove the filters for future cases
+				Eventually(func() error {
+					managedOCS := managedOCSTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+					managedOCS.Spec.MonitoringResources = v1.MonitoringResourcesSpec{}
+					return k8sClient.Update(ctx, managedOCS)
+				}, timeout, interval).Should(Succeed())
+				Eventually(func() bool {
+					return utils.ResourceHasLabel(k8sClient, ctx, promRuleTemplate.DeepCopy(), monLabelKey, monLabelValue)
+				}, timeout, interval).Should(BeTrue())
+			})
+		})
 		When("the addon config map does not exist while all other uninstall conditions are met", func() {
 			It("should not delete the managedOCS resource", func() {
 				setupUninstallConditions(false, testAddonConfigMapDeleteLabelKey, true, true, true, false, false)
@@ -893,6 +1770,40 @@ var _ = Describe("ManagedOCS controller", func() {
 				}, timeout, interval).Should(Succeed())
 			})
 		})
+		When("there are obcs while all other uninstall conditions are met", func() {
+			It("should not delete the managedOCS resource", func() {
+				obc := obcTemplate.DeepCopy()
+				Expect(k8sClient.Create(ctx, obc)).Should(Succeed())
+				setupUninstallConditions(true, testAddonConfigMapDeleteLabelKey, true, true, true, false, false)
+
+				managedOCS := managedOCSTemplate.DeepCopy()
+				key := utils.GetResourceKey((managedOCS))
+				Eventually(func() string {
+					return getTrueConditionReason(v1.ConditionUninstallBlocked)
+				}, timeout, interval).Should(Equal(reasonConsumerOBCsFound))
+				Consistently(func() error {
+					return k8sClient.Get(ctx, key, managedOCS)
+				}, timeout, interval).Should(Succeed())
+
+				// The pending uninstall fires the alert muting the storage alerts
+				Eventually(func() bool {
+					rule := dmsPromRuleTemplate.DeepCopy()
+					Expect(k8sClient.Get(ctx, utils.GetResourceKey(rule), rule)).Should(Succeed())
+					for _, group := range rule.Spec.Groups {
+						for _, alert := range group.Rules {
+							if alert.Alert == uninstallAlertName {
+								return true
+							}
+						}
+					}
+					return false
+				}, timeout, interval).Should(BeTrue())
+
+				// Remove the obc and the uninstall request for future cases
+				setupUninstallConditions(false, testAddonConfigMapDeleteLabelKey, true, true, true, false, false)
+				Expect(k8sClient.Delete(ctx, obc)).Should(Succeed())
+			})
+		})
 		When("All uninstall conditions are met", func() {
 			It("should delete the managedOCS", func() {
 				setupUninstallConditions(true, testAddonConfigMapDeleteLabelKey, true, true, true, false, false)
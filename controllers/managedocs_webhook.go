@@ -0,0 +1,383 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	pathpkg "path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	"github.com/openshift/ocs-osd-deployer/utils"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	managedOCSValidationPath = "/validate-ocs-openshift-io-v1alpha1-managedocs"
+)
+
+// +kubebuilder:webhook:path=/validate-ocs-openshift-io-v1alpha1-managedocs,mutating=false,failurePolicy=fail,sideEffects=None,groups=ocs.openshift.io,resources=managedocs,verbs=create;update;delete,versions=v1alpha1,name=vmanagedocs.ocs.openshift.io,admissionReviewVersions=v1beta1
+
+// ManagedOCSValidator validates changes to ManagedOCS resources
+type ManagedOCSValidator struct {
+	Client client.Client
+
+	// RejectUnauthorizedDeletion denies deleting a ManagedOCS resource unless an add-on
+	// uninstall was requested or the resource is annotated for forced deletion
+	RejectUnauthorizedDeletion   bool
+	AddonConfigMapName           string
+	AddonConfigMapDeleteLabelKey string
+
+	decoder *admission.Decoder
+}
+
+// SetupWithManager registers the validating webhook with the webhook server of the provided manager
+func (v *ManagedOCSValidator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(managedOCSValidationPath, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// InjectDecoder injects the admission request decoder
+func (v *ManagedOCSValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle admits or denies a ManagedOCS admission request
+func (v *ManagedOCSValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	// Delete requests only carry the old object
+	managedOCS := &v1.ManagedOCS{}
+	oldManagedOCS := &v1.ManagedOCS{}
+	if req.Operation != admissionv1beta1.Delete {
+		if err := v.decoder.Decode(req, managedOCS); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if req.Operation != admissionv1beta1.Create {
+		if err := v.decoder.DecodeRaw(req.OldObject, oldManagedOCS); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	var err error
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		err = v.validateCreate(ctx, managedOCS)
+	case admissionv1beta1.Update:
+		err = v.validateUpdate(oldManagedOCS, managedOCS)
+	case admissionv1beta1.Delete:
+		err = v.validateDelete(ctx, oldManagedOCS)
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
+func (v *ManagedOCSValidator) validateCreate(ctx context.Context, managedOCS *v1.ManagedOCS) error {
+	managedOCSList := &v1.ManagedOCSList{}
+	if err := v.Client.List(ctx, managedOCSList, client.InNamespace(managedOCS.Namespace)); err != nil {
+		return fmt.Errorf("unable to list ManagedOCS resources: %v", err)
+	}
+	for i := range managedOCSList.Items {
+		// Creating an existing resource is left to the API server to reject
+		if name := managedOCSList.Items[i].Name; name != managedOCS.Name {
+			return fmt.Errorf("ManagedOCS %q already exists in namespace %q", name, managedOCS.Namespace)
+		}
+	}
+	return validateSpec(&managedOCS.Spec)
+}
+
+// validateUpdate rejects removing the deployer finalizer and changing the immutable fields.
+// The only immutable spec field is the type of an additional storage class, the volumes
+// provisioned through the storage class are bound to the driver of its type
+func (v *ManagedOCSValidator) validateUpdate(oldManagedOCS *v1.ManagedOCS, managedOCS *v1.ManagedOCS) error {
+	// The finalizer protects the managed components and can only be dropped once deletion started
+	if managedOCS.DeletionTimestamp.IsZero() &&
+		utils.Contains(oldManagedOCS.GetFinalizers(), ManagedOCSFinalizer) &&
+		!utils.Contains(managedOCS.GetFinalizers(), ManagedOCSFinalizer) {
+		return fmt.Errorf("the %s finalizer cannot be removed", ManagedOCSFinalizer)
+	}
+
+	// A spec admitted while the webhook was disabled, or before a check was added, must not
+	// block the finalizer updates of the deployer or the deletion
+	if !managedOCS.DeletionTimestamp.IsZero() || reflect.DeepEqual(oldManagedOCS.Spec, managedOCS.Spec) {
+		return nil
+	}
+
+	oldTypes := map[string]v1.StorageClassType{}
+	for _, storageClass := range oldManagedOCS.Spec.StorageClasses {
+		oldTypes[storageClass.Name] = storageClass.Type
+	}
+	for i, storageClass := range managedOCS.Spec.StorageClasses {
+		if oldType, ok := oldTypes[storageClass.Name]; ok && oldType != storageClass.Type {
+			return fmt.Errorf("spec.storageClasses[%d].type: field is immutable", i)
+		}
+	}
+
+	return validateSpec(&managedOCS.Spec)
+}
+
+func (v *ManagedOCSValidator) validateDelete(ctx context.Context, managedOCS *v1.ManagedOCS) error {
+	if !v.RejectUnauthorizedDeletion || isForceDeleteRequested(managedOCS) {
+		return nil
+	}
+	requested, err := isAddonUninstallRequested(ctx, v.Client, managedOCS.Namespace,
+		v.AddonConfigMapName, v.AddonConfigMapDeleteLabelKey)
+	if err != nil {
+		return fmt.Errorf("unable to verify the add-on uninstall request: %v", err)
+	}
+	if !requested {
+		return fmt.Errorf("ManagedOCS can only be deleted through the add-on uninstall flow, "+
+			"set the %s annotation to \"true\" to force the deletion", forceDeleteAnnotationKey)
+	}
+	return nil
+}
+
+func validateSpec(spec *v1.ManagedOCSSpec) error {
+	components := &spec.Components
+	strategies := []struct {
+		path          string
+		value         v1.ReconcileStrategy
+		supportsMerge bool
+	}{
+		{"spec.reconcileStrategy", spec.ReconcileStrategy, true},
+		{"spec.components.storageCluster.reconcileStrategy", components.StorageCluster.ReconcileStrategy, true},
+		{"spec.components.prometheus.reconcileStrategy", components.Prometheus.ReconcileStrategy, false},
+		{"spec.components.alertmanager.reconcileStrategy", components.Alertmanager.ReconcileStrategy, false},
+		{"spec.components.alertmanagerConfig.reconcileStrategy", components.AlertmanagerConfig.ReconcileStrategy, false},
+		{"spec.components.monitoringLabels.reconcileStrategy", components.MonitoringLabels.ReconcileStrategy, false},
+	}
+	for _, strategy := range strategies {
+		if err := validateReconcileStrategy(strategy.path, strategy.value, strategy.supportsMerge); err != nil {
+			return err
+		}
+	}
+
+	if overrides := components.StorageCluster.Overrides; overrides != nil {
+		switch overrides.PatchType {
+		case "", v1.PatchTypeStrategicMerge, v1.PatchTypeJSON:
+		default:
+			return fmt.Errorf("spec.components.storageCluster.overrides.patchType: unsupported value %q", overrides.PatchType)
+		}
+		if overrides.Patch != "" && overrides.ConfigMapKeyRef != nil {
+			return fmt.Errorf("spec.components.storageCluster.overrides: patch and configMapKeyRef are mutually exclusive")
+		}
+	}
+
+	storageClassNames := map[string]bool{}
+	for i, storageClass := range spec.StorageClasses {
+		path := fmt.Sprintf("spec.storageClasses[%d]", i)
+		switch {
+		case storageClass.Name == "":
+			return fmt.Errorf("%s.name: required value", path)
+		case storageClass.Name == storageClassRbdName, storageClass.Name == storageClassCephFSName:
+			return fmt.Errorf("%s.name: %q is reserved for the storage classes created by ocs-operator", path, storageClass.Name)
+		case storageClassNames[storageClass.Name]:
+			return fmt.Errorf("%s.name: duplicate value %q", path, storageClass.Name)
+		}
+		storageClassNames[storageClass.Name] = true
+
+		switch storageClass.Type {
+		case v1.StorageClassTypeRBD, v1.StorageClassTypeCephFS:
+		default:
+			return fmt.Errorf("%s.type: unsupported value %q", path, storageClass.Type)
+		}
+		if storageClass.FSType != "" && storageClass.Type != v1.StorageClassTypeRBD {
+			return fmt.Errorf("%s.fsType: only supported for rbd storage classes", path)
+		}
+	}
+
+	severities := []struct {
+		path  string
+		value *v1.SeverityRouteSpec
+	}{
+		{"spec.alerting.severities.critical", &spec.Alerting.Severities.Critical},
+		{"spec.alerting.severities.warning", &spec.Alerting.Severities.Warning},
+		{"spec.alerting.severities.info", &spec.Alerting.Severities.Info},
+	}
+	for _, severity := range severities {
+		durations := map[string]string{
+			"groupWait":      severity.value.GroupWait,
+			"groupInterval":  severity.value.GroupInterval,
+			"repeatInterval": severity.value.RepeatInterval,
+		}
+		for field, duration := range durations {
+			if duration == "" {
+				continue
+			}
+			if _, err := model.ParseDuration(duration); err != nil {
+				return fmt.Errorf("%s.%s: %v", severity.path, field, err)
+			}
+		}
+	}
+
+	ceph := mergeCephAlertsSpec(&spec.Alerting.Ceph)
+	if ceph.NearFullPercent >= ceph.FullPercent {
+		return fmt.Errorf("spec.alerting.ceph.nearFullPercent: must be lower than fullPercent (%d)", ceph.FullPercent)
+	}
+	cephDurations := map[string]string{
+		"osdDownFor":       spec.Alerting.Ceph.OSDDownFor,
+		"monQuorumLostFor": spec.Alerting.Ceph.MonQuorumLostFor,
+		"pgStuckFor":       spec.Alerting.Ceph.PGStuckFor,
+		"slowOpsFor":       spec.Alerting.Ceph.SlowOpsFor,
+	}
+	for field, duration := range cephDurations {
+		if duration == "" {
+			continue
+		}
+		if _, err := model.ParseDuration(duration); err != nil {
+			return fmt.Errorf("spec.alerting.ceph.%s: %v", field, err)
+		}
+	}
+	if runbookURL := spec.Alerting.Ceph.RunbookBaseURL; runbookURL != "" {
+		parsed, err := url.Parse(runbookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("spec.alerting.ceph.runbookBaseURL: %q is not an http(s) URL", runbookURL)
+		}
+	}
+
+	for i, route := range spec.Alerting.Routes {
+		path := fmt.Sprintf("spec.alerting.routes[%d]", i)
+		if route.Receiver == "" {
+			return fmt.Errorf("%s.receiver: required value", path)
+		}
+		if len(route.Match) == 0 && len(route.MatchRE) == 0 {
+			return fmt.Errorf("%s: at least one of match and matchRE is required", path)
+		}
+		for label, expression := range route.MatchRE {
+			if _, err := regexp.Compile(expression); err != nil {
+				return fmt.Errorf("%s.matchRE[%s]: %v", path, label, err)
+			}
+		}
+	}
+
+	for i, rule := range spec.Alerting.InhibitRules {
+		path := fmt.Sprintf("spec.alerting.inhibitRules[%d]", i)
+		if len(rule.SourceMatch) == 0 && len(rule.SourceMatchRE) == 0 {
+			return fmt.Errorf("%s: at least one of sourceMatch and sourceMatchRE is required", path)
+		}
+		if len(rule.TargetMatch) == 0 && len(rule.TargetMatchRE) == 0 {
+			return fmt.Errorf("%s: at least one of targetMatch and targetMatchRE is required", path)
+		}
+		expressions := []struct {
+			field  string
+			values map[string]string
+		}{
+			{"sourceMatchRE", rule.SourceMatchRE},
+			{"targetMatchRE", rule.TargetMatchRE},
+		}
+		for _, expression := range expressions {
+			for label, value := range expression.values {
+				if _, err := regexp.Compile(value); err != nil {
+					return fmt.Errorf("%s.%s[%s]: %v", path, expression.field, label, err)
+				}
+			}
+		}
+	}
+
+	windowNames := map[string]bool{}
+	for i := range spec.MaintenanceWindows {
+		window := &spec.MaintenanceWindows[i]
+		path := fmt.Sprintf("spec.maintenanceWindows[%d]", i)
+		switch {
+		case window.Name == "":
+			return fmt.Errorf("%s.name: required value", path)
+		case windowNames[window.Name]:
+			return fmt.Errorf("%s.name: duplicate value %q", path, window.Name)
+		}
+		windowNames[window.Name] = true
+
+		oneOff := window.Start != nil || window.End != nil
+		recurring := window.Schedule != "" || window.Duration != nil
+		if oneOff && recurring {
+			return fmt.Errorf("%s: start and end are mutually exclusive with schedule and duration", path)
+		}
+		start, end, err := maintenanceWindowOccurrence(window, time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("%s.end: must be after start", path)
+		}
+
+		if len(window.Matchers) == 0 {
+			return fmt.Errorf("%s.matchers: at least one matcher is required", path)
+		}
+		for j, matcher := range window.Matchers {
+			if matcher.Name == "" {
+				return fmt.Errorf("%s.matchers[%d].name: required value", path, j)
+			}
+			if matcher.IsRegex {
+				if _, err := regexp.Compile(matcher.Value); err != nil {
+					return fmt.Errorf("%s.matchers[%d].value: %v", path, j, err)
+				}
+			}
+		}
+	}
+
+	filters := []struct {
+		path      string
+		selectors []v1.MonitoringResourceSelector
+	}{
+		{"spec.monitoringResources.allow", spec.MonitoringResources.Allow},
+		{"spec.monitoringResources.deny", spec.MonitoringResources.Deny},
+	}
+	for _, filter := range filters {
+		for i, selector := range filter.selectors {
+			path := fmt.Sprintf("%s[%d]", filter.path, i)
+			if selector.Kind == "" && selector.NamePattern == "" && selector.LabelSelector == nil {
+				return fmt.Errorf("%s: at least one of kind, namePattern and labelSelector is required", path)
+			}
+			if _, err := pathpkg.Match(selector.NamePattern, ""); err != nil {
+				return fmt.Errorf("%s.namePattern: %v", path, err)
+			}
+			if selector.LabelSelector != nil {
+				if _, err := metav1.LabelSelectorAsSelector(selector.LabelSelector); err != nil {
+					return fmt.Errorf("%s.labelSelector: %v", path, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateReconcileStrategy(path string, strategy v1.ReconcileStrategy, supportsMerge bool) error {
+	switch {
+	case strategy == "",
+		strings.EqualFold(string(strategy), string(v1.ReconcileStrategyStrict)),
+		strings.EqualFold(string(strategy), string(v1.ReconcileStrategyNone)):
+		return nil
+	case strings.EqualFold(string(strategy), string(v1.ReconcileStrategyMerge)) && supportsMerge:
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported value %q", path, strategy)
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ocsv1 "github.com/openshift/ocs-operator/pkg/apis/ocs/v1"
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	utils "github.com/openshift/ocs-osd-deployer/testutils"
+	ctrlutils "github.com/openshift/ocs-osd-deployer/utils"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("ManagedOCS validating webhook", func() {
+	const (
+		timeout  = time.Second * 3
+		interval = time.Millisecond * 250
+	)
+
+	ctx := context.Background()
+	managedOCSTemplate := &v1.ManagedOCS{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      managedOCSName,
+			Namespace: testSecondaryNamespace,
+		},
+	}
+	scTemplate := &ocsv1.StorageCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storageClusterName,
+			Namespace: testSecondaryNamespace,
+		},
+	}
+
+	deleteRequest := func(managedOCS *v1.ManagedOCS) admission.Request {
+		raw, err := json.Marshal(managedOCS)
+		Expect(err).ToNot(HaveOccurred())
+		return admission.Request{
+			AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Delete,
+				Namespace: managedOCS.Namespace,
+				Name:      managedOCS.Name,
+				OldObject: runtime.RawExtension{Raw: raw},
+			},
+		}
+	}
+
+	Context("validation", func() {
+		It("should allow creating the first ManagedOCS in a namespace", func() {
+			Expect(k8sClient.Create(ctx, managedOCSTemplate.DeepCopy())).Should(Succeed())
+
+			// Wait for the deployer to add its finalizer
+			Eventually(func() bool {
+				managedOCS := managedOCSTemplate.DeepCopy()
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				return ctrlutils.Contains(managedOCS.GetFinalizers(), ManagedOCSFinalizer)
+			}, timeout, interval).Should(BeTrue())
+		})
+		It("should reject creating a second ManagedOCS in the same namespace", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			managedOCS.Name = "second-managedocs"
+			Expect(k8sClient.Create(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject an unknown reconcile strategy", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.ReconcileStrategy = "bogus"
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject the merge reconcile strategy for components other than the storagecluster", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.Components.Prometheus.ReconcileStrategy = v1.ReconcileStrategyMerge
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should allow a valid reconcile strategy", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Eventually(func() error {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				managedOCS.Spec.Components.StorageCluster.ReconcileStrategy = v1.ReconcileStrategyMerge
+				return k8sClient.Update(ctx, managedOCS)
+			}, timeout, interval).Should(Succeed())
+		})
+		It("should reject removing the deployer finalizer", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.SetFinalizers(ctrlutils.Remove(managedOCS.GetFinalizers(), ManagedOCSFinalizer))
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject duplicate storage class names", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.StorageClasses = []v1.StorageClassSpec{
+				{Name: "duplicate-rbd", Type: v1.StorageClassTypeRBD},
+				{Name: "duplicate-rbd", Type: v1.StorageClassTypeRBD, FSType: "xfs"},
+			}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject alert routes without matchers", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.Alerting.Routes = []v1.AlertRouteSpec{{Receiver: "pagerduty"}}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject inhibit rules without target matchers", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.Alerting.InhibitRules = []v1.InhibitRuleSpec{{
+				SourceMatch: map[string]string{"alertname": "CephClusterErrorState"},
+			}}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject maintenance windows with an invalid schedule", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.MaintenanceWindows = []v1.MaintenanceWindowSpec{{
+				Name:     "weekly",
+				Schedule: "every saturday",
+				Duration: &metav1.Duration{Duration: time.Hour},
+				Matchers: []v1.SilenceMatcher{{Name: "namespace", Value: testSecondaryNamespace}},
+			}}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject a near full threshold above the full threshold", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.Alerting.Ceph.NearFullPercent = 90
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject monitoring resource filters without selectors", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.MonitoringResources.Deny = []v1.MonitoringResourceSelector{{}}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject storage classes named after the ocs-operator storage classes", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			managedOCS.Spec.StorageClasses = []v1.StorageClassSpec{
+				{Name: storageClassRbdName, Type: v1.StorageClassTypeRBD},
+			}
+			Expect(k8sClient.Update(ctx, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should reject changing the type of a storage class", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Eventually(func() error {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				managedOCS.Spec.StorageClasses = []v1.StorageClassSpec{
+					{Name: "immutable-type", Type: v1.StorageClassTypeRBD},
+				}
+				return k8sClient.Update(ctx, managedOCS)
+			}, timeout, interval).Should(Succeed())
+
+			// Retry on conflicts with the deployer updating the status, the rejection is final
+			Eventually(func() error {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				managedOCS.Spec.StorageClasses[0].Type = v1.StorageClassTypeCephFS
+				return k8sClient.Update(ctx, managedOCS)
+			}, timeout, interval).Should(MatchError(ContainSubstring("field is immutable")))
+
+			Eventually(func() error {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				managedOCS.Spec.StorageClasses = nil
+				return k8sClient.Update(ctx, managedOCS)
+			}, timeout, interval).Should(Succeed())
+		})
+		It("should not validate an unchanged spec", func() {
+			validator := &ManagedOCSValidator{}
+			oldManagedOCS := managedOCSTemplate.DeepCopy()
+			oldManagedOCS.Spec.ReconcileStrategy = "bogus"
+			managedOCS := oldManagedOCS.DeepCopy()
+			managedOCS.SetFinalizers([]string{ManagedOCSFinalizer})
+			Expect(validator.validateUpdate(oldManagedOCS, managedOCS)).Should(Succeed())
+
+			managedOCS.Spec.Components.Prometheus.ReconcileStrategy = v1.ReconcileStrategyNone
+			Expect(validator.validateUpdate(oldManagedOCS, managedOCS)).ShouldNot(Succeed())
+		})
+		It("should not validate the spec of a ManagedOCS being deleted", func() {
+			validator := &ManagedOCSValidator{}
+			oldManagedOCS := managedOCSTemplate.DeepCopy()
+			oldManagedOCS.Spec.ReconcileStrategy = "bogus"
+			oldManagedOCS.SetFinalizers([]string{ManagedOCSFinalizer})
+			managedOCS := oldManagedOCS.DeepCopy()
+			now := metav1.Now()
+			managedOCS.DeletionTimestamp = &now
+			managedOCS.SetFinalizers(nil)
+			Expect(validator.validateUpdate(oldManagedOCS, managedOCS)).Should(Succeed())
+		})
+	})
+
+	Context("deletion protection", func() {
+		It("should reject deleting ManagedOCS without an uninstall request when configured to", func() {
+			decoder, err := admission.NewDecoder(scheme.Scheme)
+			Expect(err).ToNot(HaveOccurred())
+			validator := &ManagedOCSValidator{
+				Client:                       k8sClient,
+				RejectUnauthorizedDeletion:   true,
+				AddonConfigMapName:           testAddonConfigMapName,
+				AddonConfigMapDeleteLabelKey: testAddonConfigMapDeleteLabelKey,
+			}
+			Expect(validator.InjectDecoder(decoder)).Should(Succeed())
+
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+			Expect(validator.Handle(ctx, deleteRequest(managedOCS)).Allowed).Should(BeFalse())
+
+			managedOCS.SetAnnotations(map[string]string{forceDeleteAnnotationKey: "true"})
+			Expect(validator.Handle(ctx, deleteRequest(managedOCS)).Allowed).Should(BeTrue())
+		})
+		It("should not delete the components without an uninstall request", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+
+			sc := scTemplate.DeepCopy()
+			Expect(controllerutil.SetControllerReference(managedOCS, sc, scheme.Scheme)).Should(Succeed())
+			Expect(k8sClient.Create(ctx, sc)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, managedOCS)).Should(Succeed())
+
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				condition := meta.FindStatusCondition(managedOCS.Status.Conditions, v1.ConditionUninstallBlocked)
+				if condition == nil || condition.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return condition.Reason
+			}, timeout, interval).Should(Equal(reasonDeletionNotAuthorized))
+			Consistently(func() error {
+				return k8sClient.Get(ctx, utils.GetResourceKey(sc), sc)
+			}, timeout, interval).Should(Succeed())
+		})
+		It("should delete the components when the deletion is forced", func() {
+			managedOCS := managedOCSTemplate.DeepCopy()
+			Eventually(func() error {
+				Expect(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS)).Should(Succeed())
+				managedOCS.SetAnnotations(map[string]string{forceDeleteAnnotationKey: "true"})
+				return k8sClient.Update(ctx, managedOCS)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				sc := scTemplate.DeepCopy()
+				return errors.IsNotFound(k8sClient.Get(ctx, utils.GetResourceKey(sc), sc))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, utils.GetResourceKey(managedOCS), managedOCS))
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,91 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+// monitoringResource is a PodMonitor, ServiceMonitor or PrometheusRule of the deployer namespace
+type monitoringResource struct {
+	kind v1.MonitoringResourceKind
+	obj  interface {
+		metav1.Object
+		runtime.Object
+	}
+}
+
+// isMonitoringResourceAdopted checks a monitoring resource against the allow and deny lists
+// of the spec. The rules of the deployer are always adopted
+func isMonitoringResourceAdopted(spec *v1.MonitoringResourcesSpec, kind v1.MonitoringResourceKind, obj metav1.Object) (bool, error) {
+	if kind == v1.MonitoringResourceKindPrometheusRule {
+		if name := obj.GetName(); name == dmsRuleName || name == cephRuleName {
+			return true, nil
+		}
+	}
+
+	denied, err := matchesMonitoringResourceSelectors(spec.Deny, kind, obj)
+	if err != nil || denied {
+		return false, err
+	}
+	if len(spec.Allow) == 0 {
+		return true, nil
+	}
+	return matchesMonitoringResourceSelectors(spec.Allow, kind, obj)
+}
+
+func matchesMonitoringResourceSelectors(selectors []v1.MonitoringResourceSelector, kind v1.MonitoringResourceKind, obj metav1.Object) (bool, error) {
+	for i := range selectors {
+		matches, err := matchesMonitoringResourceSelector(&selectors[i], kind, obj)
+		if err != nil || matches {
+			return matches, err
+		}
+	}
+	return false, nil
+}
+
+func matchesMonitoringResourceSelector(selector *v1.MonitoringResourceSelector, kind v1.MonitoringResourceKind, obj metav1.Object) (bool, error) {
+	if selector.Kind != "" && selector.Kind != kind {
+		return false, nil
+	}
+	if selector.NamePattern != "" {
+		matches, err := path.Match(selector.NamePattern, obj.GetName())
+		if err != nil {
+			return false, fmt.Errorf("invalid name pattern %q: %v", selector.NamePattern, err)
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+	if selector.LabelSelector != nil {
+		labelSelector, err := metav1.LabelSelectorAsSelector(selector.LabelSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid label selector: %v", err)
+		}
+		if !labelSelector.Matches(labels.Set(obj.GetLabels())) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+func TestMonitoringResourceFilters(t *testing.T) {
+	spec := &v1.MonitoringResourcesSpec{
+		Allow: []v1.MonitoringResourceSelector{
+			{NamePattern: "rook-ceph-*"},
+			{LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "storage"}}},
+		},
+		Deny: []v1.MonitoringResourceSelector{
+			{Kind: v1.MonitoringResourceKindPrometheusRule, NamePattern: "rook-ceph-debug-*"},
+		},
+	}
+	cases := []struct {
+		kind    v1.MonitoringResourceKind
+		name    string
+		labels  map[string]string
+		adopted bool
+	}{
+		{v1.MonitoringResourceKindServiceMonitor, "rook-ceph-mgr", nil, true},
+		{v1.MonitoringResourceKindPrometheusRule, "custom-rules", map[string]string{"team": "storage"}, true},
+		{v1.MonitoringResourceKindPrometheusRule, "custom-rules", map[string]string{"team": "apps"}, false},
+		{v1.MonitoringResourceKindPrometheusRule, "rook-ceph-debug-rules", nil, false},
+		{v1.MonitoringResourceKindServiceMonitor, "rook-ceph-debug-exporter", nil, true},
+		// The rules of the deployer are always adopted
+		{v1.MonitoringResourceKindPrometheusRule, dmsRuleName, nil, true},
+		{v1.MonitoringResourceKindPrometheusRule, cephRuleName, nil, true},
+	}
+	for _, c := range cases {
+		obj := &metav1.ObjectMeta{Name: c.name, Labels: c.labels}
+		adopted, err := isMonitoringResourceAdopted(spec, c.kind, obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if adopted != c.adopted {
+			t.Errorf("expected %s %s with labels %v to be adopted=%v", c.kind, c.name, c.labels, c.adopted)
+		}
+	}
+}
+
+func TestMonitoringResourceFiltersAllowAllByDefault(t *testing.T) {
+	adopted, err := isMonitoringResourceAdopted(&v1.MonitoringResourcesSpec{}, v1.MonitoringResourceKindPodMonitor, &metav1.ObjectMeta{Name: "anything"})
+	if err != nil || !adopted {
+		t.Fatalf("expected resources to be adopted without filters, got %v, %v", adopted, err)
+	}
+}
+
+func TestMonitoringResourceFiltersRejectInvalidSelectors(t *testing.T) {
+	spec := &v1.MonitoringResourcesSpec{
+		Deny: []v1.MonitoringResourceSelector{{NamePattern: "rook-ceph-["}},
+	}
+	if _, err := isMonitoringResourceAdopted(spec, v1.MonitoringResourceKindPodMonitor, &metav1.ObjectMeta{Name: "rook-ceph-mgr"}); err == nil {
+		t.Fatalf("expected an invalid name pattern error")
+	}
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	goerrors "errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+)
+
+func TestLastErrorIsTruncatedOnRuneBoundary(t *testing.T) {
+	r := &ManagedOCSReconciler{managedOCS: &v1.ManagedOCS{}}
+	// An odd prefix puts the two byte runes across the length limit
+	r.updateReconcileStatus(goerrors.New("x" + strings.Repeat("é", maxLastErrorLength)))
+
+	lastError := r.managedOCS.Status.LastError
+	if len(lastError) > maxLastErrorLength {
+		t.Errorf("expected the last error to be at most %d bytes, found %d", maxLastErrorLength, len(lastError))
+	}
+	if !utf8.ValidString(lastError) {
+		t.Errorf("expected the last error to be valid UTF-8")
+	}
+	if len(lastError) < maxLastErrorLength-utf8.UTFMax {
+		t.Errorf("expected the last error to keep as much as possible, found %d bytes", len(lastError))
+	}
+}
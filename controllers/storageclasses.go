@@ -0,0 +1,296 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	snapv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Storage classes and snapshot classes are cluster scoped and cannot be owned by the
+	// namespaced ManagedOCS resource, the label records the namespace of the owner instead
+	storageClassOwnerLabelKey = "ocs.openshift.io/managedocs-namespace"
+
+	cephBlockPoolName  = "ocs-storagecluster-cephblockpool"
+	cephFilesystemName = "ocs-storagecluster-cephfilesystem"
+)
+
+// reconcileStorageClasses creates and updates the additional storage classes and snapshot
+// classes listed in the ManagedOCS spec, and removes the ones that are no longer listed
+func (r *ManagedOCSReconciler) reconcileStorageClasses() error {
+	r.Log.Info("Reconciling additional StorageClasses")
+
+	desiredStorageClasses := map[string]bool{}
+	desiredSnapshotClasses := map[string]bool{}
+	for i := range r.managedOCS.Spec.StorageClasses {
+		spec := &r.managedOCS.Spec.StorageClasses[i]
+		if err := r.reconcileStorageClass(spec); err != nil {
+			return err
+		}
+		desiredStorageClasses[spec.Name] = true
+
+		if spec.SnapshotClass != nil {
+			if err := r.reconcileVolumeSnapshotClass(spec); err != nil {
+				return err
+			}
+			desiredSnapshotClasses[spec.Name] = true
+		}
+	}
+
+	return r.deleteStorageClasses(desiredStorageClasses, desiredSnapshotClasses)
+}
+
+func (r *ManagedOCSReconciler) reconcileStorageClass(spec *v1.StorageClassSpec) error {
+	desired := r.newStorageClass(spec)
+
+	current := &storagev1.StorageClass{}
+	err := r.UnrestrictedClient.Get(r.ctx, client.ObjectKey{Name: spec.Name}, current)
+	if errors.IsNotFound(err) {
+		r.Log.Info("Creating storage class", "StorageClass", spec.Name)
+		return r.UnrestrictedClient.Create(r.ctx, desired)
+	} else if err != nil {
+		return fmt.Errorf("unable to get storage class %s: %v", spec.Name, err)
+	}
+	if current.Labels[storageClassOwnerLabelKey] != r.namespace {
+		return newConfigurationError(reasonStorageClassConflict,
+			"Storage class %s already exists and is not managed by the deployer", spec.Name)
+	}
+
+	// Most of the storage class fields are immutable, a change requires recreating the storage
+	// class. Existing volumes are not affected
+	if current.Provisioner != desired.Provisioner ||
+		!equality.Semantic.DeepEqual(current.Parameters, desired.Parameters) ||
+		!equality.Semantic.DeepEqual(current.ReclaimPolicy, desired.ReclaimPolicy) ||
+		!equality.Semantic.DeepEqual(current.VolumeBindingMode, desired.VolumeBindingMode) {
+		r.Log.Info("Recreating storage class", "StorageClass", spec.Name)
+		if err := r.UnrestrictedClient.Delete(r.ctx, current); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete storage class %s: %v", spec.Name, err)
+		}
+		return r.UnrestrictedClient.Create(r.ctx, desired)
+	}
+
+	if !equality.Semantic.DeepEqual(current.AllowVolumeExpansion, desired.AllowVolumeExpansion) ||
+		!equality.Semantic.DeepEqual(current.MountOptions, desired.MountOptions) {
+		current.AllowVolumeExpansion = desired.AllowVolumeExpansion
+		current.MountOptions = desired.MountOptions
+		return r.UnrestrictedClient.Update(r.ctx, current)
+	}
+	return nil
+}
+
+func (r *ManagedOCSReconciler) reconcileVolumeSnapshotClass(spec *v1.StorageClassSpec) error {
+	desired := r.newVolumeSnapshotClass(spec)
+
+	current := &snapv1beta1.VolumeSnapshotClass{}
+	err := r.UnrestrictedClient.Get(r.ctx, client.ObjectKey{Name: spec.Name}, current)
+	if errors.IsNotFound(err) {
+		r.Log.Info("Creating volume snapshot class", "VolumeSnapshotClass", spec.Name)
+		return r.UnrestrictedClient.Create(r.ctx, desired)
+	} else if err != nil {
+		return fmt.Errorf("unable to get volume snapshot class %s: %v", spec.Name, err)
+	}
+	if current.Labels[storageClassOwnerLabelKey] != r.namespace {
+		return newConfigurationError(reasonStorageClassConflict,
+			"Volume snapshot class %s already exists and is not managed by the deployer", spec.Name)
+	}
+
+	if current.Driver != desired.Driver ||
+		current.DeletionPolicy != desired.DeletionPolicy ||
+		!equality.Semantic.DeepEqual(current.Parameters, desired.Parameters) {
+		current.Driver = desired.Driver
+		current.Parameters = desired.Parameters
+		current.DeletionPolicy = desired.DeletionPolicy
+		return r.UnrestrictedClient.Update(r.ctx, current)
+	}
+	return nil
+}
+
+// deleteStorageClasses removes the storage classes and snapshot classes owned by the deployer
+// that are not listed in the given sets
+func (r *ManagedOCSReconciler) deleteStorageClasses(keepStorageClasses map[string]bool, keepSnapshotClasses map[string]bool) error {
+	ownerLabel := client.MatchingLabels{storageClassOwnerLabelKey: r.namespace}
+
+	storageClassList := &storagev1.StorageClassList{}
+	if err := r.UnrestrictedClient.List(r.ctx, storageClassList, ownerLabel); err != nil {
+		return fmt.Errorf("unable to list storage classes: %v", err)
+	}
+	for i := range storageClassList.Items {
+		storageClass := &storageClassList.Items[i]
+		if keepStorageClasses[storageClass.Name] {
+			continue
+		}
+		r.Log.Info("Deleting storage class", "StorageClass", storageClass.Name)
+		if err := r.UnrestrictedClient.Delete(r.ctx, storageClass); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete storage class %s: %v", storageClass.Name, err)
+		}
+	}
+
+	snapshotClassList := &snapv1beta1.VolumeSnapshotClassList{}
+	if err := r.UnrestrictedClient.List(r.ctx, snapshotClassList, ownerLabel); err != nil {
+		// The VolumeSnapshotClass CRD is installed along with the external snapshotter
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to list volume snapshot classes: %v", err)
+	}
+	for i := range snapshotClassList.Items {
+		snapshotClass := &snapshotClassList.Items[i]
+		if keepSnapshotClasses[snapshotClass.Name] {
+			continue
+		}
+		r.Log.Info("Deleting volume snapshot class", "VolumeSnapshotClass", snapshotClass.Name)
+		if err := r.UnrestrictedClient.Delete(r.ctx, snapshotClass); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete volume snapshot class %s: %v", snapshotClass.Name, err)
+		}
+	}
+	return nil
+}
+
+// ownedStorageClassesExist checks whether any storage class or snapshot class owned by the
+// deployer is left
+func (r *ManagedOCSReconciler) ownedStorageClassesExist() (bool, error) {
+	ownerLabel := client.MatchingLabels{storageClassOwnerLabelKey: r.namespace}
+
+	storageClassList := &storagev1.StorageClassList{}
+	if err := r.UnrestrictedClient.List(r.ctx, storageClassList, ownerLabel); err != nil {
+		return false, fmt.Errorf("unable to list storage classes: %v", err)
+	}
+	if len(storageClassList.Items) > 0 {
+		return true, nil
+	}
+
+	snapshotClassList := &snapv1beta1.VolumeSnapshotClassList{}
+	if err := r.UnrestrictedClient.List(r.ctx, snapshotClassList, ownerLabel); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to list volume snapshot classes: %v", err)
+	}
+	return len(snapshotClassList.Items) > 0, nil
+}
+
+// listOCSStorageClassNames returns the names of the storage classes backed by the storage cluster
+func (r *ManagedOCSReconciler) listOCSStorageClassNames() ([]string, error) {
+	names := []string{storageClassRbdName, storageClassCephFSName}
+	storageClassList := &storagev1.StorageClassList{}
+	ownerLabel := client.MatchingLabels{storageClassOwnerLabelKey: r.namespace}
+	if err := r.UnrestrictedClient.List(r.ctx, storageClassList, ownerLabel); err != nil {
+		return nil, fmt.Errorf("unable to list storage classes: %v", err)
+	}
+	for i := range storageClassList.Items {
+		names = append(names, storageClassList.Items[i].Name)
+	}
+	for i := range r.managedOCS.Spec.StorageClasses {
+		names = append(names, r.managedOCS.Spec.StorageClasses[i].Name)
+	}
+	return names, nil
+}
+
+func (r *ManagedOCSReconciler) newStorageClass(spec *v1.StorageClassSpec) *storagev1.StorageClass {
+	storageClass := &storagev1.StorageClass{}
+	storageClass.Name = spec.Name
+	storageClass.Labels = map[string]string{storageClassOwnerLabelKey: r.namespace}
+	storageClass.ReclaimPolicy = spec.ReclaimPolicy
+	storageClass.VolumeBindingMode = spec.VolumeBindingMode
+	storageClass.AllowVolumeExpansion = spec.AllowVolumeExpansion
+	storageClass.MountOptions = spec.MountOptions
+	storageClass.Provisioner = r.csiDriverName(spec.Type)
+
+	// The default parameters match the storage classes created by ocs-operator
+	parameters := map[string]string{
+		"clusterID": r.namespace,
+		"csi.storage.k8s.io/provisioner-secret-namespace":       r.namespace,
+		"csi.storage.k8s.io/node-stage-secret-namespace":        r.namespace,
+		"csi.storage.k8s.io/controller-expand-secret-namespace": r.namespace,
+	}
+	if spec.Type == v1.StorageClassTypeCephFS {
+		parameters["fsName"] = cephFilesystemName
+		parameters["csi.storage.k8s.io/provisioner-secret-name"] = "rook-csi-cephfs-provisioner"
+		parameters["csi.storage.k8s.io/node-stage-secret-name"] = "rook-csi-cephfs-node"
+		parameters["csi.storage.k8s.io/controller-expand-secret-name"] = "rook-csi-cephfs-provisioner"
+	} else {
+		fsType := spec.FSType
+		if fsType == "" {
+			fsType = "ext4"
+		}
+		parameters["pool"] = cephBlockPoolName
+		parameters["imageFormat"] = "2"
+		parameters["imageFeatures"] = "layering"
+		parameters["csi.storage.k8s.io/fstype"] = fsType
+		parameters["csi.storage.k8s.io/provisioner-secret-name"] = "rook-csi-rbd-provisioner"
+		parameters["csi.storage.k8s.io/node-stage-secret-name"] = "rook-csi-rbd-node"
+		parameters["csi.storage.k8s.io/controller-expand-secret-name"] = "rook-csi-rbd-provisioner"
+	}
+	for key, value := range spec.Parameters {
+		parameters[key] = value
+	}
+	storageClass.Parameters = parameters
+
+	return storageClass
+}
+
+func (r *ManagedOCSReconciler) newVolumeSnapshotClass(spec *v1.StorageClassSpec) *snapv1beta1.VolumeSnapshotClass {
+	snapshotClass := &snapv1beta1.VolumeSnapshotClass{}
+	snapshotClass.Name = spec.Name
+	snapshotClass.Labels = map[string]string{storageClassOwnerLabelKey: r.namespace}
+	snapshotClass.Driver = r.csiDriverName(spec.Type)
+	snapshotClass.DeletionPolicy = snapv1beta1.VolumeSnapshotContentDelete
+	if spec.SnapshotClass.DeletionPolicy == string(snapv1beta1.VolumeSnapshotContentRetain) {
+		snapshotClass.DeletionPolicy = snapv1beta1.VolumeSnapshotContentRetain
+	}
+
+	secretName := "rook-csi-rbd-provisioner"
+	if spec.Type == v1.StorageClassTypeCephFS {
+		secretName = "rook-csi-cephfs-provisioner"
+	}
+	snapshotClass.Parameters = map[string]string{
+		"clusterID": r.namespace,
+		"csi.storage.k8s.io/snapshotter-secret-name":      secretName,
+		"csi.storage.k8s.io/snapshotter-secret-namespace": r.namespace,
+	}
+	return snapshotClass
+}
+
+// csiDriverName returns the name of the ceph csi driver deployed by rook for the storage class type
+func (r *ManagedOCSReconciler) csiDriverName(storageClassType v1.StorageClassType) string {
+	if storageClassType == v1.StorageClassTypeCephFS {
+		return fmt.Sprintf("%s.cephfs.csi.ceph.com", r.namespace)
+	}
+	return fmt.Sprintf("%s.rbd.csi.ceph.com", r.namespace)
+}
+
+// persistentVolumeClaimUsesStorageClass checks whether a pvc uses one of the given storage classes
+func persistentVolumeClaimUsesStorageClass(pvc *corev1.PersistentVolumeClaim, storageClassNames []string) bool {
+	if pvc.Spec.StorageClassName == nil {
+		return false
+	}
+	for _, name := range storageClassNames {
+		if *pvc.Spec.StorageClassName == name {
+			return true
+		}
+	}
+	return false
+}
@@ -20,10 +20,13 @@ import (
 	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -34,8 +37,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	snapv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	nbv1 "github.com/noobaa/noobaa-operator/v2/pkg/apis/noobaa/v1alpha1"
+	configv1 "github.com/openshift/api/config/v1"
 	ocsv1 "github.com/openshift/ocs-operator/pkg/apis"
 	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
+	"github.com/openshift/ocs-osd-deployer/templates"
 	opv1a1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
@@ -50,6 +58,8 @@ var testEnv *envtest.Environment
 const (
 	testPrimaryNamespace             = "primary"
 	testSecondaryNamespace           = "secondary"
+	testWorkerNodeName               = "worker-0"
+	testStorageClassName             = "gp2"
 	testAddonParamsSecretName        = "test-addon-secret"
 	testPagerdutySecretName          = "test-pagerduty-secret"
 	testDeadMansSnitchSecretName     = "test-deadmanssnitch-secret"
@@ -70,12 +80,19 @@ func TestAPIs(t *testing.T) {
 var _ = BeforeSuite(func(done Done) {
 	logf.SetLogger(zap.LoggerTo(GinkgoWriter, true))
 
+	Expect(templates.Load()).To(Succeed())
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths: []string{
 			filepath.Join("..", "config", "crd", "bases"),
 			filepath.Join("..", "shim", "crds"),
 		},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			DirectoryPaths: []string{
+				filepath.Join("..", "config", "webhook"),
+			},
+		},
 	}
 
 	var err error
@@ -95,10 +112,26 @@ var _ = BeforeSuite(func(done Done) {
 	err = opv1a1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = configv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = snapv1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = nbv1.SchemeBuilder.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = obv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	// +kubebuilder:scaffold:scheme
 
+	webhookOptions := &testEnv.WebhookInstallOptions
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
-		Scheme: scheme.Scheme,
+		Scheme:  scheme.Scheme,
+		Host:    webhookOptions.LocalServingHost,
+		Port:    webhookOptions.LocalServingPort,
+		CertDir: webhookOptions.LocalServingCertDir,
 	})
 	Expect(err).ToNot(HaveOccurred())
 
@@ -107,6 +140,7 @@ var _ = BeforeSuite(func(done Done) {
 		UnrestrictedClient:           k8sManager.GetClient(),
 		Log:                          ctrl.Log.WithName("controllers").WithName("ManagedOCS"),
 		Scheme:                       scheme.Scheme,
+		Recorder:                     k8sManager.GetEventRecorderFor("managedocs-controller"),
 		AddonParamSecretName:         testAddonParamsSecretName,
 		AddonConfigMapName:           testAddonConfigMapName,
 		AddonConfigMapDeleteLabelKey: testAddonConfigMapDeleteLabelKey,
@@ -116,6 +150,11 @@ var _ = BeforeSuite(func(done Done) {
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	err = (&ManagedOCSValidator{
+		Client: k8sManager.GetClient(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
 	go func() {
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
 		Expect(err).ToNot(HaveOccurred())
@@ -138,6 +177,29 @@ var _ = BeforeSuite(func(done Done) {
 	secondaryNS.Name = testSecondaryNamespace
 	Expect(k8sClient.Create(ctx, secondaryNS)).Should(Succeed())
 
+	// Create a worker node to be used as a storage node
+	workerNode := &corev1.Node{}
+	workerNode.Name = testWorkerNodeName
+	workerNode.Labels = map[string]string{"node-role.kubernetes.io/worker": ""}
+	Expect(k8sClient.Create(ctx, workerNode)).Should(Succeed())
+	workerNode.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("32"),
+		corev1.ResourceMemory: resource.MustParse("128Gi"),
+	}
+	Expect(k8sClient.Status().Update(ctx, workerNode)).Should(Succeed())
+
+	// Create a mock AWS infrastructure with its storage class
+	infrastructure := &configv1.Infrastructure{}
+	infrastructure.Name = "cluster"
+	Expect(k8sClient.Create(ctx, infrastructure)).Should(Succeed())
+	infrastructure.Status.PlatformStatus = &configv1.PlatformStatus{Type: configv1.AWSPlatformType}
+	Expect(k8sClient.Status().Update(ctx, infrastructure)).Should(Succeed())
+
+	gp2StorageClass := &storagev1.StorageClass{}
+	gp2StorageClass.Name = testStorageClassName
+	gp2StorageClass.Provisioner = "kubernetes.io/aws-ebs"
+	Expect(k8sClient.Create(ctx, gp2StorageClass)).Should(Succeed())
+
 	// Create a mock subscription
 	deployerSub := &opv1a1.Subscription{}
 	deployerSub.Name = testSubscriptionName
@@ -157,7 +219,10 @@ var _ = BeforeSuite(func(done Done) {
 	managedOCS := &v1.ManagedOCS{}
 	managedOCS.Name = managedOCSName
 	managedOCS.Namespace = testPrimaryNamespace
-	Expect(k8sClient.Create(ctx, managedOCS)).ShouldNot(HaveOccurred())
+	// Creation is validated by the webhook which might not be served yet
+	Eventually(func() error {
+		return k8sClient.Create(ctx, managedOCS)
+	}, 10*time.Second, 250*time.Millisecond).ShouldNot(HaveOccurred())
 
 	close(done)
 }, 60)
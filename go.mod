@@ -1,20 +1,29 @@
 module github.com/openshift/ocs-osd-deployer
 
-go 1.15
+go 1.16
 
 require (
 	github.com/coreos/prometheus-operator v0.38.0
+	github.com/evanphx/json-patch v4.9.0+incompatible
 	github.com/go-logr/logr v0.3.0
 	github.com/go-logr/zapr v0.2.0 // indirect
+	github.com/kube-object-storage/lib-bucket-provisioner v0.0.0-20200610144127-e2eec875d6d1
+	github.com/kubernetes-csi/external-snapshotter/v2 v2.1.1
+	github.com/noobaa/noobaa-operator/v2 v2.3.0
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/openshift/api v3.9.1-0.20190924102528-32369d4db2ad+incompatible
 	github.com/openshift/ocs-operator v0.0.1-alpha1.0.20201201172124-0811c33c21b2
 	github.com/operator-framework/api v0.1.1
+	github.com/prometheus/common v0.10.0
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/rook/rook v1.4.6
 	go.uber.org/zap v1.14.1
 	k8s.io/api v0.19.3
 	k8s.io/apimachinery v0.19.3
 	k8s.io/client-go v12.0.0+incompatible
 	sigs.k8s.io/controller-runtime v0.6.3
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
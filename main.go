@@ -21,24 +21,32 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/go-logr/logr"
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	snapv1beta1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	nbv1 "github.com/noobaa/noobaa-operator/v2/pkg/apis/noobaa/v1alpha1"
+	configv1 "github.com/openshift/api/config/v1"
 	ocsv1 "github.com/openshift/ocs-operator/pkg/apis"
 	v1 "github.com/openshift/ocs-osd-deployer/api/v1alpha1"
 	"github.com/openshift/ocs-osd-deployer/controllers"
+	"github.com/openshift/ocs-osd-deployer/templates"
 	operators "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
@@ -73,20 +81,56 @@ func addAllSchemes(scheme *runtime.Scheme) {
 
 	utilruntime.Must(operators.AddToScheme(scheme))
 
+	utilruntime.Must(configv1.AddToScheme(scheme))
+
+	utilruntime.Must(snapv1beta1.AddToScheme(scheme))
+
+	utilruntime.Must(nbv1.SchemeBuilder.AddToScheme(scheme))
+
+	utilruntime.Must(obv1.AddToScheme(scheme))
+
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhooks bool
+	var rejectUnauthorizedDeletion bool
+	var templatesDir string
+	var alertmanagerURL string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the ManagedOCS validating webhook. "+
+			"Requires serving certificates to be mounted for the webhook server.")
+	flag.BoolVar(&rejectUnauthorizedDeletion, "reject-unauthorized-deletion", false,
+		"Reject deleting the ManagedOCS resource outside of the add-on uninstall flow. "+
+			"Only effective when webhooks are enabled.")
+	flag.StringVar(&templatesDir, "templates-dir", "",
+		"Development only: load the resource templates from the manifests in this directory "+
+			"instead of the ones embedded in the binary.")
+	flag.StringVar(&alertmanagerURL, "alertmanager-url", "",
+		"The address of the deployer alertmanager API. "+
+			"Defaults to the alertmanager service of the deployer namespace.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.StacktraceLevel(zapcore.ErrorLevel)))
 
+	if err := templates.Load(); err != nil {
+		setupLog.Error(err, "Failed to load the embedded resource templates")
+		os.Exit(1)
+	}
+	if templatesDir != "" {
+		setupLog.Info("Loading resource templates", "directory", templatesDir)
+		if err := templates.LoadFromDirectory(templatesDir); err != nil {
+			setupLog.Error(err, "Failed to load resource templates")
+			os.Exit(1)
+		}
+	}
+
 	envVars, err := readEnvVars()
 	if err != nil {
 		setupLog.Error(err, "Failed to get environment variables")
@@ -112,19 +156,44 @@ func main() {
 		UnrestrictedClient:           getUnrestrictedClient(),
 		Log:                          ctrl.Log.WithName("controllers").WithName("ManagedOCS"),
 		Scheme:                       mgr.GetScheme(),
+		Recorder:                     mgr.GetEventRecorderFor("managedocs-controller"),
 		AddonParamSecretName:         fmt.Sprintf("addon-%v-parameters", addonName),
 		AddonConfigMapName:           addonName,
 		AddonConfigMapDeleteLabelKey: fmt.Sprintf("api.openshift.com/addon-%v-delete", addonName),
 		DeployerSubscriptionName:     fmt.Sprintf("addon-%v", addonName),
 		PagerdutySecretName:          fmt.Sprintf("%v-pagerduty", addonName),
 		DeadMansSnitchSecretName:     fmt.Sprintf("%v-deadmanssnitch", addonName),
+		AlertmanagerURL:              alertmanagerURL,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "Unable to create controller", "controller", "ManagedOCS")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = (&controllers.ManagedOCSValidator{
+			Client:                       mgr.GetClient(),
+			RejectUnauthorizedDeletion:   rejectUnauthorizedDeletion,
+			AddonConfigMapName:           addonName,
+			AddonConfigMapDeleteLabelKey: fmt.Sprintf("api.openshift.com/addon-%v-delete", addonName),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "Unable to create webhook", "webhook", "ManagedOCS")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
-	if err := ensureManagedOCS(mgr.GetClient(), setupLog, envVars); err != nil {
+	if enableWebhooks {
+		// The ManagedOCS resource is validated by our own webhook, so it can only
+		// be created once the manager is running and serving the webhook
+		err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			return wait.PollImmediateUntil(5*time.Second, func() (bool, error) {
+				return ensureManagedOCS(mgr.GetClient(), setupLog, envVars) == nil, nil
+			}, stop)
+		}))
+		if err != nil {
+			setupLog.Error(err, "Unable to add ManagedOCS initialization to the manager")
+			os.Exit(1)
+		}
+	} else if err := ensureManagedOCS(mgr.GetClient(), setupLog, envVars); err != nil {
 		os.Exit(1)
 	}
 
@@ -1,31 +0,0 @@
-/*
-
-
-Licensed under the Apache License, Version 2.0 (the "License");
-you may not use this file except in compliance with the License.
-You may obtain a copy of the License at
-
-    http://www.apache.org/licenses/LICENSE-2.0
-
-Unless required by applicable law or agreed to in writing, software
-distributed under the License is distributed on an "AS IS" BASIS,
-WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-See the License for the specific language governing permissions and
-limitations under the License.
-*/
-
-package templates
-
-import (
-	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
-)
-
-// AlertmanagerTemplate is the template that serves as the base for the Alert Manager deployed by the operator
-var _3 = int32(3)
-
-var AlertmanagerTemplate = promv1.Alertmanager{
-	Spec: promv1.AlertmanagerSpec{
-		Replicas:     &_3,
-		ConfigSecret: "managed-ocs-alertmanager-config-secret",
-	},
-}
@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourceProfile holds the resource requirements of the storage cluster daemons
+type ResourceProfile struct {
+	Mds corev1.ResourceRequirements `json:"mds"`
+	Mgr corev1.ResourceRequirements `json:"mgr"`
+	Mon corev1.ResourceRequirements `json:"mon"`
+	Osd corev1.ResourceRequirements `json:"osd"`
+}
+
+const (
+	ResourceProfileSmall  = "small"
+	ResourceProfileMedium = "medium"
+	ResourceProfileLarge  = "large"
+)
+
+// ResourceProfiles are the resource profiles the deployer picks from based on the cluster size.
+// They are decoded from the embedded resource profiles manifest along with the templates
+var ResourceProfiles map[string]ResourceProfile
+
+// decodeResourceProfiles decodes the resource profiles manifest, rejecting unknown fields and
+// missing profiles
+func decodeResourceProfiles(data []byte) (map[string]ResourceProfile, error) {
+	profiles := map[string]ResourceProfile{}
+	if err := yaml.UnmarshalStrict(data, &profiles); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{ResourceProfileSmall, ResourceProfileMedium, ResourceProfileLarge} {
+		if _, ok := profiles[name]; !ok {
+			return nil, fmt.Errorf("missing %s resource profile", name)
+		}
+	}
+	return profiles, nil
+}
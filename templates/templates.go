@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	ocsapis "github.com/openshift/ocs-operator/pkg/apis"
+	ocsv1 "github.com/openshift/ocs-operator/pkg/apis/ocs/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// The templates serve as the base for the resources deployed by the operator. They are
+// decoded from the embedded manifests by Load
+var (
+	StorageClusterTemplate    ocsv1.StorageCluster
+	PrometheusTemplate        promv1.Prometheus
+	AlertmanagerTemplate      promv1.Alertmanager
+	DMSPrometheusRuleTemplate promv1.PrometheusRule
+)
+
+const (
+	storageClusterManifest    = "storagecluster.yaml"
+	prometheusManifest        = "prometheus.yaml"
+	alertmanagerManifest      = "alertmanager.yaml"
+	dmsPrometheusRuleManifest = "dmsprometheusrule.yaml"
+	resourceProfilesManifest  = "resourceprofiles.yaml"
+)
+
+//go:embed manifests/*.yaml
+var embeddedManifests embed.FS
+
+var scheme = runtime.NewScheme()
+
+// decoder rejects manifests with unknown or duplicate fields
+var decoder = json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme, scheme, json.SerializerOptions{
+	Yaml:   true,
+	Strict: true,
+})
+
+func init() {
+	utilruntime.Must(ocsapis.AddToScheme(scheme))
+	utilruntime.Must(promv1.AddToScheme(scheme))
+}
+
+// Load decodes the templates and resource profiles from the manifests embedded in the binary.
+// Bad manifests are a build defect, the operator is expected to fail fast on an error
+func Load() error {
+	return load(embeddedManifests, "manifests")
+}
+
+// LoadFromDirectory replaces the embedded templates with the manifests found in dir. It is
+// meant for development, to try template changes without rebuilding the operator. The
+// templates are left untouched if any of the manifests is missing or invalid
+func LoadFromDirectory(dir string) error {
+	return load(os.DirFS(dir), ".")
+}
+
+func load(fsys fs.FS, dir string) error {
+	storageCluster := &ocsv1.StorageCluster{}
+	prometheus := &promv1.Prometheus{}
+	alertmanager := &promv1.Alertmanager{}
+	dmsPrometheusRule := &promv1.PrometheusRule{}
+
+	manifests := []struct {
+		name string
+		into runtime.Object
+	}{
+		{storageClusterManifest, storageCluster},
+		{prometheusManifest, prometheus},
+		{alertmanagerManifest, alertmanager},
+		{dmsPrometheusRuleManifest, dmsPrometheusRule},
+	}
+	for _, manifest := range manifests {
+		data, err := fs.ReadFile(fsys, path.Join(dir, manifest.name))
+		if err != nil {
+			return fmt.Errorf("unable to read template %s: %v", manifest.name, err)
+		}
+		if err := decode(data, manifest.into); err != nil {
+			return fmt.Errorf("unable to decode template %s: %v", manifest.name, err)
+		}
+	}
+	data, err := fs.ReadFile(fsys, path.Join(dir, resourceProfilesManifest))
+	if err != nil {
+		return fmt.Errorf("unable to read resource profiles %s: %v", resourceProfilesManifest, err)
+	}
+	resourceProfiles, err := decodeResourceProfiles(data)
+	if err != nil {
+		return fmt.Errorf("unable to decode resource profiles %s: %v", resourceProfilesManifest, err)
+	}
+
+	StorageClusterTemplate = *storageCluster
+	PrometheusTemplate = *prometheus
+	AlertmanagerTemplate = *alertmanager
+	DMSPrometheusRuleTemplate = *dmsPrometheusRule
+	ResourceProfiles = resourceProfiles
+	return nil
+}
+
+// decode decodes a manifest into the given object, verifying that the manifest kind
+// matches the type of the object
+func decode(data []byte, into runtime.Object) error {
+	kinds, _, err := scheme.ObjectKinds(into)
+	if err != nil {
+		return err
+	}
+	_, gvk, err := decoder.Decode(data, nil, into)
+	if err != nil {
+		return err
+	}
+	if *gvk != kinds[0] {
+		return fmt.Errorf("expected %v, found %v", kinds[0], *gvk)
+	}
+	return nil
+}
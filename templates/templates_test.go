@@ -0,0 +1,111 @@
+package templates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+func TestEmbeddedTemplates(t *testing.T) {
+	if err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(StorageClusterTemplate.Spec.StorageDeviceSets) != 1 {
+		t.Fatalf("expected a single storage device set, found %d", len(StorageClusterTemplate.Spec.StorageDeviceSets))
+	}
+	if AlertmanagerTemplate.Spec.Replicas == nil || *AlertmanagerTemplate.Spec.Replicas != 3 {
+		t.Fatalf("expected 3 alertmanager replicas")
+	}
+	if PrometheusTemplate.Spec.Alerting.Alertmanagers[0].Port.StrVal != "web" {
+		t.Fatalf("expected the alertmanager endpoint to use the web port")
+	}
+	if len(DMSPrometheusRuleTemplate.Spec.Groups) != 1 {
+		t.Fatalf("expected a single dms rule group")
+	}
+	mds := ResourceProfiles[ResourceProfileLarge].Mds.Limits["memory"]
+	if mds.String() != "16Gi" {
+		t.Fatalf("expected a 16Gi mds memory limit in the large resource profile, found %v", mds)
+	}
+}
+
+func TestDecodeResourceProfilesRejectsMissingProfile(t *testing.T) {
+	manifest := `
+small: {}
+medium: {}
+`
+	_, err := decodeResourceProfiles([]byte(manifest))
+	if err == nil || !strings.Contains(err.Error(), "missing large resource profile") {
+		t.Fatalf("expected a missing profile error, got %v", err)
+	}
+}
+
+func TestDecodeRejectsUnknownFields(t *testing.T) {
+	manifest := `
+apiVersion: monitoring.coreos.com/v1
+kind: Alertmanager
+spec:
+  replicas: 3
+  replica: 3
+`
+	err := decode([]byte(manifest), &promv1.Alertmanager{})
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("expected an unknown field error, got %v", err)
+	}
+}
+
+func TestDecodeRejectsMismatchedKind(t *testing.T) {
+	manifest := `
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+spec: {}
+`
+	if err := decode([]byte(manifest), &promv1.Alertmanager{}); err == nil {
+		t.Fatalf("expected a kind mismatch error")
+	}
+}
+
+func TestLoadFromDirectoryKeepsTemplatesOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{storageClusterManifest, prometheusManifest, alertmanagerManifest, dmsPrometheusRuleManifest, resourceProfilesManifest} {
+		data, err := embeddedManifests.ReadFile("manifests/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == alertmanagerManifest {
+			data = []byte(strings.Replace(string(data), "replicas: 3", "replicas: 5", 1))
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadFromDirectory(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *AlertmanagerTemplate.Spec.Replicas != 5 {
+		t.Fatalf("expected the template to be loaded from the directory")
+	}
+
+	if err := os.Remove(filepath.Join(dir, prometheusManifest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFromDirectory(dir); err == nil {
+		t.Fatalf("expected an error for a missing manifest")
+	}
+	if *AlertmanagerTemplate.Spec.Replicas != 5 {
+		t.Fatalf("expected the templates to be kept on error")
+	}
+
+	// Restore the embedded templates
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}